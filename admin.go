@@ -0,0 +1,327 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// adminListLimit caps how many rows an admin listing shows
+const adminListLimit = 500
+
+// adminPage is the data rendered by admin.html
+type adminPage struct {
+	User       *User
+	IsAdmin    bool
+	Tab        string
+	Search     string
+	Sort       string
+	Desc       bool
+	Status     string
+	Links      []AdminLink
+	Users      []UserSummary
+	Reports    []Report
+	Limit      int
+	BaseDomain string
+	CSRFToken  string
+
+	// Audit log tab
+	Events       []AuditEvent
+	AuditActions []auditAction
+	AuditFilter  url.Values
+	ExportURL    string
+}
+
+// SortLink returns the URL that sorts the current tab by key, flipping the direction
+// when it is already sorted by key
+func (p adminPage) SortLink(key string) string {
+	query := url.Values{"sort": {key}}
+	if p.Search != "" {
+		query.Set("q", p.Search)
+	}
+	if p.Sort == key && !p.Desc {
+		query.Set("desc", "1")
+	}
+	return "/admin/" + p.Tab + "?" + query.Encode()
+}
+
+// SortMark returns the arrow shown next to the column the listing is sorted by
+func (p adminPage) SortMark(key string) string {
+	switch {
+	case p.Sort != key:
+		return ""
+	case p.Desc:
+		return " ▼"
+	default:
+		return " ▲"
+	}
+}
+
+// requireAdmin returns the current user if they are an admin. Anyone else is sent to log in
+// or shown an access denied page.
+func (s *Server) requireAdmin(w http.ResponseWriter, r *http.Request) (*User, bool) {
+	user, err := s.getCurrentUser(r)
+	if err != nil {
+		// Not authenticated, redirect to login
+		s.redirectToLogin(w, r)
+		return nil, false
+	}
+
+	if !s.config.IsAdmin(user.ID) {
+		s.renderError(w, 403, "Access Denied",
+			"You need to be an administrator to view this page.",
+			"Admins are listed by Discord user ID in the server config.")
+		return nil, false
+	}
+	return user, true
+}
+
+// HandleAdmin routes administration panel requests
+func (s *Server) handleAdmin(w http.ResponseWriter, r *http.Request, adminPath string) {
+	// The report queue is also open to moderators
+	if adminPath == "reports" || strings.HasPrefix(adminPath, "reports/") {
+		s.handleModeration(w, r, strings.TrimPrefix(strings.TrimPrefix(adminPath, "reports"), "/"))
+		return
+	}
+
+	user, ok := s.requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	switch adminPath {
+	case "", "links", "users":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleAdminList(w, r, user, adminPath)
+		return
+	case "audit", "audit/export":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if adminPath == "audit" {
+			s.handleAdminAudit(w, r, user)
+		} else {
+			s.handleAdminAuditExport(w, r)
+		}
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch adminPath {
+	case "links/delete":
+		s.handleAdminDeleteLink(w, r, user)
+	case "links/reassign":
+		s.handleAdminReassignLink(w, r, user)
+	case "links/disable":
+		s.handleAdminSetLinkDisabled(w, r, user, true)
+	case "links/enable":
+		s.handleAdminSetLinkDisabled(w, r, user, false)
+	case "users/disable":
+		s.handleAdminSetUserDisabled(w, r, user, true)
+	case "users/enable":
+		s.handleAdminSetUserDisabled(w, r, user, false)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// HandleAdminList shows every link or user, with search and sorting
+func (s *Server) handleAdminList(w http.ResponseWriter, r *http.Request, user *User, tab string) {
+	if tab == "" {
+		tab = "links"
+	}
+
+	query := AdminQuery{
+		Search: strings.TrimSpace(r.URL.Query().Get("q")),
+		Sort:   r.URL.Query().Get("sort"),
+		Desc:   r.URL.Query().Get("desc") != "",
+		Limit:  adminListLimit,
+	}
+
+	page := adminPage{
+		User:       user,
+		IsAdmin:    true,
+		Tab:        tab,
+		Search:     query.Search,
+		Sort:       query.Sort,
+		Desc:       query.Desc,
+		Limit:      adminListLimit,
+		BaseDomain: s.getBaseDomain(r.Host),
+		CSRFToken:  s.csrfToken(r),
+	}
+
+	var err error
+	if tab == "links" {
+		page.Links, err = s.store.ListAllLinks(r.Context(), query)
+		for i := range page.Links {
+			page.Links[i].CreatedAt = formatTimestamp(page.Links[i].CreatedAt)
+			describeExpiry(&page.Links[i].URLMapping)
+			describeHealth(&page.Links[i].URLMapping)
+		}
+	} else {
+		page.Users, err = s.store.ListUsers(r.Context(), query)
+		for i := range page.Users {
+			page.Users[i].CreatedAt = formatTimestamp(page.Users[i].CreatedAt)
+			if page.Users[i].DisabledAt != nil {
+				disabled := formatTimestamp(*page.Users[i].DisabledAt)
+				page.Users[i].DisabledAt = &disabled
+			}
+		}
+	}
+	if err != nil {
+		s.renderError(w, 500, "Database Error", "Failed to load the admin listing", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	err = s.templates.ExecuteTemplate(w, "admin.html", page)
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		log.Printf("Template error: %v", err)
+	}
+}
+
+// HandleAdminDeleteLink deletes any link
+func (s *Server) handleAdminDeleteLink(w http.ResponseWriter, r *http.Request, admin *User) {
+	shortCode := strings.ToLower(strings.TrimSpace(r.FormValue("short_code")))
+	if shortCode == "" {
+		http.Error(w, "Short code is required", http.StatusBadRequest)
+		return
+	}
+
+	previous := s.auditedLink(r.Context(), shortCode)
+	if err := s.store.AdminDeleteLink(r.Context(), shortCode); err != nil {
+		s.renderAdminError(w, err, fmt.Sprintf("The short code '%s' was not found.", shortCode), "Failed to delete link")
+		return
+	}
+	s.cache.Invalidate(shortCode)
+	log.Printf("Admin deleted link %s", shortCode)
+
+	event := newAuditEvent(admin.ID, AuditAdminLinkDelete, shortCode)
+	if previous != nil {
+		event.SubjectID = previous.OwnerID
+		event.OldURL = &previous.DiscordURL
+	}
+	s.audit(r.Context(), event)
+
+	http.Redirect(w, r, "/admin/links", http.StatusFound)
+}
+
+// HandleAdminReassignLink moves any link to another user
+func (s *Server) handleAdminReassignLink(w http.ResponseWriter, r *http.Request, admin *User) {
+	shortCode := strings.ToLower(strings.TrimSpace(r.FormValue("short_code")))
+	ownerID := strings.TrimSpace(r.FormValue("owner_id"))
+	if shortCode == "" || ownerID == "" {
+		http.Error(w, "Short code and new owner ID are required", http.StatusBadRequest)
+		return
+	}
+
+	previous := s.auditedLink(r.Context(), shortCode)
+	if err := s.store.ReassignLink(r.Context(), shortCode, ownerID); err != nil {
+		s.renderAdminError(w, err,
+			fmt.Sprintf("Either the short code '%s' or the user '%s' was not found.", shortCode, ownerID),
+			"Failed to reassign link")
+		return
+	}
+	log.Printf("Admin reassigned link %s to user %s", shortCode, ownerID)
+
+	event := newAuditEvent(admin.ID, AuditAdminLinkReassign, shortCode)
+	event.SubjectID = &ownerID
+	if previous != nil && previous.OwnerID != nil {
+		event.Details = "from user " + *previous.OwnerID
+	}
+	s.audit(r.Context(), event)
+
+	http.Redirect(w, r, "/admin/links", http.StatusFound)
+}
+
+// HandleAdminSetLinkDisabled disables or re-enables any link. Disabled links stop redirecting
+// but stay registered so the code can't be claimed again.
+func (s *Server) handleAdminSetLinkDisabled(w http.ResponseWriter, r *http.Request, admin *User, disabled bool) {
+	shortCode := strings.ToLower(strings.TrimSpace(r.FormValue("short_code")))
+	if shortCode == "" {
+		http.Error(w, "Short code is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.SetLinkDisabled(r.Context(), shortCode, disabled); err != nil {
+		s.renderAdminError(w, err, fmt.Sprintf("The short code '%s' was not found.", shortCode), "Failed to update link")
+		return
+	}
+	s.cache.Invalidate(shortCode)
+	log.Printf("Admin set link %s disabled=%t", shortCode, disabled)
+
+	action := AuditAdminLinkEnable
+	if disabled {
+		action = AuditAdminLinkDisable
+	}
+	event := newAuditEvent(admin.ID, action, shortCode)
+	if mapping := s.auditedLink(r.Context(), shortCode); mapping != nil {
+		event.SubjectID = mapping.OwnerID
+	}
+	s.audit(r.Context(), event)
+
+	http.Redirect(w, r, "/admin/links", http.StatusFound)
+}
+
+// HandleAdminSetUserDisabled disables or re-enables a user
+func (s *Server) handleAdminSetUserDisabled(w http.ResponseWriter, r *http.Request, admin *User, disabled bool) {
+	userID := strings.TrimSpace(r.FormValue("user_id"))
+	if userID == "" {
+		http.Error(w, "User ID is required", http.StatusBadRequest)
+		return
+	}
+
+	if disabled && userID == admin.ID {
+		http.Error(w, "You can't disable your own account", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.SetUserDisabled(r.Context(), userID, disabled); err != nil {
+		s.renderAdminError(w, err, fmt.Sprintf("The user '%s' was not found.", userID), "Failed to update user")
+		return
+	}
+
+	// A disabled account's links go down with it, as when a moderator bans a link's owner.
+	// Re-enabling the account leaves them disabled, to be restored one at a time.
+	action, details := AuditAdminUserEnable, ""
+	if disabled {
+		links, err := s.store.DisableUserLinks(r.Context(), userID)
+		if err != nil {
+			s.renderError(w, 500, "Database Error", "The user was disabled but their links could not be disabled", err.Error())
+			return
+		}
+		// Any of the user's links may be cached, so start afresh
+		s.cache.Clear()
+
+		action, details = AuditAdminUserDisable, fmt.Sprintf("disabled %d links", links)
+	}
+	log.Printf("Admin set user %s disabled=%t", userID, disabled)
+
+	event := newAuditEvent(admin.ID, action, "")
+	event.SubjectID = &userID
+	event.Details = details
+	s.audit(r.Context(), event)
+
+	http.Redirect(w, r, "/admin/users", http.StatusFound)
+}
+
+// RenderAdminError displays the error page matching a store error from an admin action
+func (s *Server) renderAdminError(w http.ResponseWriter, err error, notFound, failure string) {
+	if errors.Is(err, ErrNotFound) {
+		s.renderError(w, 404, "Not Found", notFound, "It may have been changed by someone else.")
+		return
+	}
+	s.renderError(w, 500, "Database Error", failure, err.Error())
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// apiLink is the JSON representation of a URL mapping
+type apiLink struct {
+	ShortCode  string  `json:"short_code"`
+	DiscordURL string  `json:"discord_url"`
+	ShortURL   string  `json:"short_url"`
+	CreatedAt  string  `json:"created_at"`
+	UpdatedAt  *string `json:"updated_at"`
+	ExpiresAt  *string `json:"expires_at"`
+	Expired    bool    `json:"expired"`
+
+	GuildID         *string `json:"guild_id"`
+	GuildName       *string `json:"guild_name"`
+	InviteExpiresAt *string `json:"invite_expires_at"`
+	Health          *string `json:"health"`
+	HealthCheckedAt *string `json:"health_checked_at"`
+	VerifiedAt      *string `json:"verified_at"`
+
+	// Role is the authenticated user's role on the link: owner or editor
+	Role string `json:"role"`
+}
+
+// apiLinkRequest is the JSON body accepted when creating or updating a link
+type apiLinkRequest struct {
+	ShortCode     string `json:"short_code"`
+	DiscordURL    string `json:"discord_url"`
+	ExpiresInDays *int   `json:"expires_in_days"`
+}
+
+// apiError is the JSON body returned for every API failure
+type apiError struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// HandleAPI routes versioned JSON API requests
+func (s *Server) handleAPI(w http.ResponseWriter, r *http.Request, apiPath string) {
+	// The OpenAPI document is public so tooling can discover the API
+	if apiPath == "v1/openapi.yaml" {
+		w.Header().Set("Content-Type", "application/yaml")
+		http.ServeFile(w, r, "assets/openapi.yaml")
+		return
+	}
+
+	if apiPath != "v1/links" && !strings.HasPrefix(apiPath, "v1/links/") {
+		s.writeAPIError(w, http.StatusNotFound, "not_found", "Unknown API endpoint")
+		return
+	}
+
+	user, err := s.getAPIUser(r)
+	if err != nil {
+		// Failed token checks share the login budget to slow down token guessing
+		if !s.allowRequest(w, r, LimitLogin, "ip:"+s.clientIP(r)) {
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="drop-reg"`)
+		s.writeAPIError(w, http.StatusUnauthorized, "unauthorized", "A valid personal access token is required")
+		return
+	}
+
+	if !s.allowRequest(w, r, LimitAPI, "user:"+user.ID) {
+		return
+	}
+
+	shortCode := strings.ToLower(strings.Trim(strings.TrimPrefix(apiPath, "v1/links"), "/"))
+	if shortCode == "" {
+		switch r.Method {
+		case http.MethodGet:
+			s.apiListLinks(w, r, user)
+		case http.MethodPost:
+			s.apiCreateLink(w, r, user)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			s.writeAPIError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.apiGetLink(w, r, user, shortCode)
+	case http.MethodPut, http.MethodPatch:
+		s.apiUpdateLink(w, r, user, shortCode)
+	case http.MethodDelete:
+		s.apiDeleteLink(w, r, user, shortCode)
+	default:
+		w.Header().Set("Allow", "GET, PUT, PATCH, DELETE")
+		s.writeAPIError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+	}
+}
+
+// getAPIUser authenticates a request by its bearer personal access token
+func (s *Server) getAPIUser(r *http.Request) (*User, error) {
+	header := r.Header.Get("Authorization")
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || strings.TrimSpace(token) == "" {
+		return nil, fmt.Errorf("missing bearer token")
+	}
+
+	return s.store.GetUserByAPIToken(r.Context(), hashAPIToken(strings.TrimSpace(token)))
+}
+
+// apiListLinks returns every link the authenticated user owns or co-manages
+func (s *Server) apiListLinks(w http.ResponseWriter, r *http.Request, user *User) {
+	links, err := s.store.ListUserLinks(r.Context(), user.ID)
+	if err != nil {
+		s.writeAPIError(w, http.StatusInternalServerError, "internal_error", "Failed to retrieve links")
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	baseDomain := s.getBaseDomain(r.Host)
+	result := make([]apiLink, 0, len(links))
+	for _, link := range links {
+		result = append(result, s.toAPILink(link, baseDomain))
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{"links": result})
+}
+
+// apiGetLink returns a single link the authenticated user may edit, marking it if it has expired
+func (s *Server) apiGetLink(w http.ResponseWriter, r *http.Request, user *User, shortCode string) {
+	mapping, ok := s.apiLoadOwnedLink(w, r, user, shortCode)
+	if !ok {
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, s.toAPILink(*mapping, s.getBaseDomain(r.Host)))
+}
+
+// apiCreateLink registers a new link for the authenticated user
+func (s *Server) apiCreateLink(w http.ResponseWriter, r *http.Request, user *User) {
+	var req apiLinkRequest
+	if !s.decodeAPIRequest(w, r, &req) {
+		return
+	}
+
+	shortCode := strings.ToLower(strings.TrimSpace(req.ShortCode))
+
+	if err := s.codePolicy.Validate(shortCode); err != nil {
+		s.writeAPIError(w, http.StatusBadRequest, "invalid_short_code", err.Error())
+		return
+	}
+
+	discordURL, err := canonicalDiscordURL(req.DiscordURL)
+	if err != nil {
+		s.writeAPIError(w, http.StatusBadRequest, "invalid_discord_url", err.Error())
+		return
+	}
+
+	expiry := LinkExpiry{}
+	if req.ExpiresInDays != nil {
+		if expiry, err = parseLinkExpiry(strconv.Itoa(*req.ExpiresInDays), ""); err != nil {
+			s.writeAPIError(w, http.StatusBadRequest, "invalid_expiry", err.Error())
+			return
+		}
+	}
+
+	invite, ok := s.apiResolveInvite(w, r, discordURL)
+	if !ok {
+		return
+	}
+	if !s.apiVerifyInvite(w, r, user, invite) {
+		return
+	}
+
+	err = s.store.CreateLink(r.Context(), shortCode, discordURL, user.ID, expiry, invite)
+	if err != nil {
+		s.writeStoreError(w, err, shortCode, "Failed to register URL")
+		return
+	}
+	s.cache.Invalidate(shortCode)
+
+	event := newAuditEvent(user.ID, AuditLinkCreate, shortCode)
+	event.NewURL = &discordURL
+	event.Details = "via API"
+	s.audit(r.Context(), event)
+
+	mapping, err := s.store.GetLink(r.Context(), shortCode)
+	if err != nil {
+		s.writeAPIError(w, http.StatusInternalServerError, "internal_error", "Failed to load created link")
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	mapping.Role = RoleOwner
+
+	w.Header().Set("Location", "/api/v1/links/"+shortCode)
+	s.writeJSON(w, http.StatusCreated, s.toAPILink(*mapping, s.getBaseDomain(r.Host)))
+}
+
+// apiUpdateLink changes the Discord destination and expiry of a link the authenticated user may
+// edit. PUT requires discord_url; PATCH changes only the fields it is given.
+func (s *Server) apiUpdateLink(w http.ResponseWriter, r *http.Request, user *User, shortCode string) {
+	var req apiLinkRequest
+	if !s.decodeAPIRequest(w, r, &req) {
+		return
+	}
+
+	if req.DiscordURL == "" && (r.Method == http.MethodPut || req.ExpiresInDays == nil) {
+		message := "discord_url is required"
+		if r.Method == http.MethodPatch {
+			message = "Nothing to update: set discord_url or expires_in_days"
+		}
+		s.writeAPIError(w, http.StatusBadRequest, "invalid_request", message)
+		return
+	}
+
+	// Validate everything before changing anything, so a bad field leaves the link untouched
+	var discordURL string
+	if req.DiscordURL != "" {
+		var err error
+		if discordURL, err = canonicalDiscordURL(req.DiscordURL); err != nil {
+			s.writeAPIError(w, http.StatusBadRequest, "invalid_discord_url", err.Error())
+			return
+		}
+	}
+
+	var expiry LinkExpiry
+	if req.ExpiresInDays != nil && *req.ExpiresInDays != 0 {
+		var err error
+		if expiry, err = parseLinkExpiry(strconv.Itoa(*req.ExpiresInDays), ""); err != nil {
+			s.writeAPIError(w, http.StatusBadRequest, "invalid_expiry", err.Error())
+			return
+		}
+	}
+
+	previous, ok := s.apiLoadOwnedLink(w, r, user, shortCode)
+	if !ok {
+		return
+	}
+	if previous.DisabledAt != nil {
+		s.writeStoreError(w, ErrLinkDisabled, shortCode, "Failed to update link")
+		return
+	}
+
+	if discordURL != "" {
+		invite, ok := s.apiResolveInvite(w, r, discordURL)
+		if !ok {
+			return
+		}
+		if !s.apiVerifyInvite(w, r, user, invite) {
+			return
+		}
+
+		if err := s.store.UpdateLinkURL(r.Context(), shortCode, user.ID, discordURL, invite); err != nil {
+			s.writeStoreError(w, err, shortCode, "Failed to update link")
+			return
+		}
+		s.cache.Invalidate(shortCode)
+
+		event := newAuditEvent(user.ID, AuditLinkUpdate, shortCode)
+		event.OldURL = &previous.DiscordURL
+		event.NewURL = &discordURL
+		event.Details = "via API"
+		onBehalfOf(event, previous)
+		s.audit(r.Context(), event)
+	}
+
+	if req.ExpiresInDays != nil {
+		if err := s.store.SetLinkExpiry(r.Context(), shortCode, user.ID, expiry); err != nil {
+			s.writeStoreError(w, err, shortCode, "Failed to update link expiry")
+			return
+		}
+		s.cache.Invalidate(shortCode)
+
+		event := newAuditEvent(user.ID, AuditLinkExpiry, shortCode)
+		event.Details = describeExpiryChange(expiry) + ", via API"
+		onBehalfOf(event, previous)
+		s.audit(r.Context(), event)
+	}
+
+	mapping, err := s.store.GetLinkRecord(r.Context(), shortCode)
+	if err != nil {
+		s.writeAPIError(w, http.StatusInternalServerError, "internal_error", "Failed to load updated link")
+		log.Printf("Database error: %v", err)
+		return
+	}
+	mapping.Role = previous.Role
+
+	s.writeJSON(w, http.StatusOK, s.toAPILink(*mapping, s.getBaseDomain(r.Host)))
+}
+
+// apiDeleteLink deletes a link the authenticated user owns or co-owns
+func (s *Server) apiDeleteLink(w http.ResponseWriter, r *http.Request, user *User, shortCode string) {
+	previous := s.auditedLink(r.Context(), shortCode)
+	if err := s.store.DeleteLink(r.Context(), shortCode, user.ID); err != nil {
+		s.writeStoreError(w, err, shortCode, "Failed to delete link")
+		return
+	}
+	s.cache.Invalidate(shortCode)
+
+	event := newAuditEvent(user.ID, AuditLinkDelete, shortCode)
+	if previous != nil {
+		event.OldURL = &previous.DiscordURL
+	}
+	event.Details = "via API"
+	onBehalfOf(event, previous)
+	s.audit(r.Context(), event)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiLoadOwnedLink loads a link, expired or not, and verifies the user may edit it, writing an
+// error if not
+func (s *Server) apiLoadOwnedLink(w http.ResponseWriter, r *http.Request, user *User, shortCode string) (*URLMapping, bool) {
+	// Expired links are still owned, so look them up without the expiry filter
+	mapping, err := s.store.GetLinkRecord(r.Context(), shortCode)
+	if err == nil {
+		mapping.Role, err = s.requireLinkRole(r.Context(), shortCode, user.ID, RoleEditor)
+	}
+
+	if err != nil {
+		s.writeStoreError(w, err, shortCode, "Failed to retrieve link")
+		return nil, false
+	}
+
+	return mapping, true
+}
+
+// apiVerifyInvite checks whether the user manages an invite's guild, writing an error if
+// unverified links are refused
+func (s *Server) apiVerifyInvite(w http.ResponseWriter, r *http.Request, user *User, invite *DiscordInvite) bool {
+	if err := s.verifyInvite(r.Context(), user.ID, invite); err != nil {
+		status, message := verifyError(err)
+		code := "guild_not_managed"
+		if status != http.StatusForbidden {
+			code = "internal_error"
+		}
+		s.writeAPIError(w, status, code, message)
+		return false
+	}
+	return true
+}
+
+// apiResolveInvite checks a Discord URL against Discord, writing an error if it can't be used
+func (s *Server) apiResolveInvite(w http.ResponseWriter, r *http.Request, discordURL string) (*DiscordInvite, bool) {
+	invite, err := s.resolveInvite(r.Context(), discordURL)
+	if err != nil {
+		status, message := inviteError(err)
+		code := "invalid_invite"
+		if status != http.StatusBadRequest {
+			code = "discord_unavailable"
+		}
+		s.writeAPIError(w, status, code, message)
+		return nil, false
+	}
+	return invite, true
+}
+
+// writeStoreError writes the JSON error matching a store error
+func (s *Server) writeStoreError(w http.ResponseWriter, err error, shortCode, failure string) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		s.writeAPIError(w, http.StatusNotFound, "not_found",
+			fmt.Sprintf("The short code '%s' was not found", shortCode))
+	case errors.Is(err, ErrNotOwner):
+		s.writeAPIError(w, http.StatusForbidden, "not_owner",
+			fmt.Sprintf("The link '%s' belongs to another user", shortCode))
+	case errors.Is(err, ErrLinkDisabled):
+		s.writeAPIError(w, http.StatusForbidden, "link_disabled",
+			fmt.Sprintf("The link '%s' has been disabled by a moderator", shortCode))
+	case errors.Is(err, ErrCodeTaken):
+		s.writeAPIError(w, http.StatusConflict, "code_taken", "Short code already exists")
+	default:
+		s.writeAPIError(w, http.StatusInternalServerError, "internal_error", failure)
+		log.Printf("Database error: %v", err)
+	}
+}
+
+// decodeAPIRequest decodes a JSON request body, writing an error if it is malformed
+func (s *Server) decodeAPIRequest(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, 64*1024)
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		s.writeAPIError(w, http.StatusBadRequest, "invalid_json", fmt.Sprintf("Invalid JSON body: %v", err))
+		return false
+	}
+	return true
+}
+
+// toAPILink converts a URL mapping to its JSON representation
+func (s *Server) toAPILink(mapping URLMapping, baseDomain string) apiLink {
+	return apiLink{
+		ShortCode:  mapping.ShortCode,
+		DiscordURL: mapping.DiscordURL,
+		ShortURL:   "https://" + shortLink(s.config, mapping.ShortCode, baseDomain),
+		CreatedAt:  mapping.CreatedAt,
+		UpdatedAt:  mapping.UpdatedAt,
+		ExpiresAt:  mapping.ExpiresAt,
+		Expired:    !isActive(&mapping),
+
+		GuildID:         mapping.GuildID,
+		GuildName:       mapping.GuildName,
+		InviteExpiresAt: mapping.InviteExpiresAt,
+		Health:          mapping.Health,
+		HealthCheckedAt: mapping.HealthCheckedAt,
+		VerifiedAt:      mapping.VerifiedAt,
+
+		Role: mapping.Role,
+	}
+}
+
+// writeJSON writes a JSON response with the given status code
+func (s *Server) writeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("JSON encode error: %v", err)
+	}
+}
+
+// writeAPIError writes a JSON error response
+func (s *Server) writeAPIError(w http.ResponseWriter, statusCode int, code, message string) {
+	var body apiError
+	body.Error.Code = code
+	body.Error.Message = message
+	s.writeJSON(w, statusCode, body)
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Audit log actions
+const (
+	AuditLinkCreate        = "link.create"
+	AuditLinkUpdate        = "link.update"
+	AuditLinkRenew         = "link.renew"
+	AuditLinkExpiry        = "link.expiry"
+	AuditLinkDelete        = "link.delete"
+	AuditLogin             = "user.login"
+	AuditLogout            = "user.logout"
+	AuditSessionRevoke     = "session.revoke"
+	AuditTokenCreate       = "token.create"
+	AuditTokenRevoke       = "token.revoke"
+	AuditAdminLinkDelete   = "admin.link.delete"
+	AuditAdminLinkReassign = "admin.link.reassign"
+	AuditAdminLinkDisable  = "admin.link.disable"
+	AuditAdminLinkEnable   = "admin.link.enable"
+	AuditAdminUserDisable  = "admin.user.disable"
+	AuditAdminUserEnable   = "admin.user.enable"
+	AuditReportDismiss     = "report.dismiss"
+	AuditReportDisableLink = "report.disable_link"
+	AuditReportBanOwner    = "report.ban_owner"
+	AuditTransferOffer     = "transfer.offer"
+	AuditTransferCancel    = "transfer.cancel"
+	AuditTransferDecline   = "transfer.decline"
+	AuditTransferAccept    = "transfer.accept"
+	AuditMemberInvite      = "member.invite"
+	AuditMemberAccept      = "member.accept"
+	AuditMemberDecline     = "member.decline"
+	AuditMemberRemove      = "member.remove"
+)
+
+// auditAction pairs an audit action with its human readable description
+type auditAction struct {
+	Action string
+	Label  string
+}
+
+// Every audit action, in the order the admin filter offers them
+var auditActions = []auditAction{
+	{AuditLinkCreate, "Created link"},
+	{AuditLinkUpdate, "Changed destination"},
+	{AuditLinkRenew, "Renewed link"},
+	{AuditLinkExpiry, "Changed expiry"},
+	{AuditLinkDelete, "Deleted link"},
+	{AuditLogin, "Logged in"},
+	{AuditLogout, "Logged out"},
+	{AuditSessionRevoke, "Signed out a session"},
+	{AuditTokenCreate, "Created API token"},
+	{AuditTokenRevoke, "Revoked API token"},
+	{AuditAdminLinkDelete, "Admin deleted link"},
+	{AuditAdminLinkReassign, "Admin reassigned link"},
+	{AuditAdminLinkDisable, "Admin disabled link"},
+	{AuditAdminLinkEnable, "Admin enabled link"},
+	{AuditAdminUserDisable, "Admin disabled user"},
+	{AuditAdminUserEnable, "Admin enabled user"},
+	{AuditReportDismiss, "Dismissed report"},
+	{AuditReportDisableLink, "Disabled reported link"},
+	{AuditReportBanOwner, "Banned reported owner"},
+	{AuditTransferOffer, "Offered link transfer"},
+	{AuditTransferCancel, "Cancelled link transfer"},
+	{AuditTransferDecline, "Declined link transfer"},
+	{AuditTransferAccept, "Accepted link transfer"},
+	{AuditMemberInvite, "Invited link member"},
+	{AuditMemberAccept, "Accepted link invitation"},
+	{AuditMemberDecline, "Declined link invitation"},
+	{AuditMemberRemove, "Removed link member"},
+}
+
+// Rows shown in the dashboard activity view and the most an export returns
+const (
+	activityLimit    = 20
+	auditExportLimit = 100000
+)
+
+// Label returns a human readable description of the event's action
+func (e AuditEvent) Label() string {
+	for _, action := range auditActions {
+		if action.Action == e.Action {
+			return action.Label
+		}
+	}
+	return e.Action
+}
+
+// Actor returns the ID of the user who made the change, or "" if unknown
+func (e AuditEvent) Actor() string {
+	if e.ActorID == nil {
+		return ""
+	}
+	return *e.ActorID
+}
+
+// optionalString returns nil for an empty string, for nullable columns
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// newAuditEvent starts an event for an action actorID took, on shortCode if not empty
+func newAuditEvent(actorID, action, shortCode string) *AuditEvent {
+	return &AuditEvent{
+		ActorID:   optionalString(actorID),
+		Action:    action,
+		ShortCode: optionalString(shortCode),
+	}
+}
+
+// audit appends an event to the audit log. The change it describes has already been made,
+// so a failure is logged rather than shown to the user.
+func (s *Server) audit(ctx context.Context, event *AuditEvent) {
+	if err := s.store.RecordAuditEvent(ctx, event); err != nil {
+		log.Printf("Failed to record audit event %s: %v", event.Action, err)
+	}
+}
+
+// auditedLink returns a link as it was before a change, so its old destination and owner can
+// be recorded. It returns nil if the link can't be loaded; the change itself reports that.
+func (s *Server) auditedLink(ctx context.Context, shortCode string) *URLMapping {
+	mapping, err := s.store.GetLinkRecord(ctx, shortCode)
+	if err != nil {
+		return nil
+	}
+	return mapping
+}
+
+// describeExpiryChange summarises a new expiry for the audit log
+func describeExpiryChange(expiry LinkExpiry) string {
+	if expiry.ExpiresAt == nil {
+		return "never expires"
+	}
+	return "expires " + dbTime(*expiry.ExpiresAt) + " UTC"
+}
+
+// formatAuditEvents converts event timestamps to a human readable form
+func formatAuditEvents(events []AuditEvent) {
+	for i := range events {
+		events[i].CreatedAt = formatTimestamp(events[i].CreatedAt)
+	}
+}
+
+// parseAuditQuery reads the admin audit filters from the query string. Dates are whole UTC
+// days, with until inclusive.
+func parseAuditQuery(values url.Values, limit int) (AuditQuery, error) {
+	query := AuditQuery{
+		UserID:    strings.TrimSpace(values.Get("user")),
+		Action:    values.Get("action"),
+		ShortCode: strings.ToLower(strings.TrimSpace(values.Get("code"))),
+		Limit:     limit,
+	}
+
+	if since := values.Get("since"); since != "" {
+		day, err := time.Parse("2006-01-02", since)
+		if err != nil {
+			return query, fmt.Errorf("invalid start date %q", since)
+		}
+		query.Since = day
+	}
+	if until := values.Get("until"); until != "" {
+		day, err := time.Parse("2006-01-02", until)
+		if err != nil {
+			return query, fmt.Errorf("invalid end date %q", until)
+		}
+		query.Until = day.Add(24 * time.Hour)
+	}
+	return query, nil
+}
+
+// csvCell stops user-supplied text from being read as a formula by spreadsheet software
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// HandleAdminAudit shows the audit log across every user, filtered by the query string
+func (s *Server) handleAdminAudit(w http.ResponseWriter, r *http.Request, user *User) {
+	query, err := parseAuditQuery(r.URL.Query(), adminListLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	events, err := s.store.ListAuditEvents(r.Context(), query)
+	if err != nil {
+		s.renderError(w, 500, "Database Error", "Failed to load the audit log", err.Error())
+		return
+	}
+	formatAuditEvents(events)
+
+	page := adminPage{
+		User:         user,
+		IsAdmin:      true,
+		Tab:          "audit",
+		Events:       events,
+		AuditActions: auditActions,
+		AuditFilter:  r.URL.Query(),
+		ExportURL:    "/admin/audit/export?" + r.URL.Query().Encode(),
+		Limit:        adminListLimit,
+		BaseDomain:   s.getBaseDomain(r.Host),
+		CSRFToken:    s.csrfToken(r),
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	err = s.templates.ExecuteTemplate(w, "admin.html", page)
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		log.Printf("Template error: %v", err)
+	}
+}
+
+// HandleAdminAuditExport downloads the filtered audit log as CSV
+func (s *Server) handleAdminAuditExport(w http.ResponseWriter, r *http.Request) {
+	query, err := parseAuditQuery(r.URL.Query(), auditExportLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	events, err := s.store.ListAuditEvents(r.Context(), query)
+	if err != nil {
+		s.renderError(w, 500, "Database Error", "Failed to export the audit log", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="audit-%s.csv"`, time.Now().UTC().Format("20060102-150405")))
+
+	deref := func(value *string) string {
+		if value == nil {
+			return ""
+		}
+		return csvCell(*value)
+	}
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"id", "created_at", "actor_id", "action", "short_code", "subject_id", "old_url", "new_url", "details"})
+	for _, event := range events {
+		// Drivers return timestamps in different layouts, so exports always use the stored one
+		createdAt := event.CreatedAt
+		if t, err := parseTimestamp(createdAt); err == nil {
+			createdAt = dbTime(t)
+		}
+
+		out.Write([]string{
+			strconv.Itoa(event.ID), createdAt, deref(event.ActorID), event.Action, deref(event.ShortCode),
+			deref(event.SubjectID), deref(event.OldURL), deref(event.NewURL), csvCell(event.Details),
+		})
+	}
+	out.Flush()
+	if err := out.Error(); err != nil {
+		log.Printf("Audit export error: %v", err)
+	}
+}
@@ -1,50 +1,277 @@
 package main
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+)
 
-	disgoauth "github.com/realTristan/disgoauth"
+// OAuth state cookie settings. The state only has to survive the trip to Discord and back.
+const (
+	oauthStateCookie   = "oauth_state"
+	oauthStateLifetime = 10 * time.Minute
 )
 
+// errInvalidOAuthState is returned when a login callback's state is missing, forged, expired or reused
+var errInvalidOAuthState = errors.New("invalid OAuth state")
+
+// oauthStateLedger remembers states that have been used until they would have expired anyway,
+// so a captured callback URL can't be replayed
+type oauthStateLedger struct {
+	mu   sync.Mutex
+	used map[string]time.Time
+}
+
+// newOAuthStateLedger creates an empty ledger
+func newOAuthStateLedger() *oauthStateLedger {
+	return &oauthStateLedger{used: make(map[string]time.Time)}
+}
+
+// consume records a state as used, reporting false if it already was
+func (l *oauthStateLedger) consume(nonce string, expiresAt time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for used, expiry := range l.used {
+		if now.After(expiry) {
+			delete(l.used, used)
+		}
+	}
+
+	if _, ok := l.used[nonce]; ok {
+		return false
+	}
+	l.used[nonce] = expiresAt
+	return true
+}
+
+// oauthStateKey derives the key that signs OAuth state cookies from the client secret, which
+// only the server knows
+func oauthStateKey(config *Config) []byte {
+	key := sha256.Sum256([]byte("drop-reg oauth state:" + config.Client.Secret))
+	return key[:]
+}
+
+// signOAuthState returns the signature of a state cookie's payload
+func (s *Server) signOAuthState(payload string) string {
+	mac := hmac.New(sha256.New, s.stateKey)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// safeReturnPath returns raw if it is a path on this site that is safe to redirect to after
+// login, and "/" otherwise
+func safeReturnPath(raw string) string {
+	// Reject protocol-relative and backslash forms browsers treat as another host
+	if !strings.HasPrefix(raw, "/") || strings.HasPrefix(raw, "//") || strings.Contains(raw, "\\") {
+		return "/"
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Scheme != "" || parsed.Host != "" || strings.HasPrefix(parsed.Path, "/auth/") {
+		return "/"
+	}
+	// The same again once percent-encoding is undone, in case anything decodes the path
+	if strings.HasPrefix(parsed.Path, "//") || strings.Contains(parsed.Path, "\\") {
+		return "/"
+	}
+	return raw
+}
+
+// redirectToLogin sends a visitor to log in, coming back to the page they asked for afterwards
+func (s *Server) redirectToLogin(w http.ResponseWriter, r *http.Request) {
+	// Only GET requests can be repeated by following a redirect
+	if r.Method != http.MethodGet || r.URL.Path == "/" {
+		http.Redirect(w, r, "/auth/login", http.StatusFound)
+		return
+	}
+	http.Redirect(w, r, "/auth/login?return_to="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+}
+
+// issueOAuthState creates a single-use state for a login and binds it to the browser with a
+// signed cookie that also carries where to go afterwards
+func (s *Server) issueOAuthState(w http.ResponseWriter, r *http.Request, returnTo string) string {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	state := hex.EncodeToString(nonce)
+
+	expiresAt := time.Now().Add(oauthStateLifetime)
+	payload := strings.Join([]string{
+		state,
+		base64.RawURLEncoding.EncodeToString([]byte(returnTo)),
+		strconv.FormatInt(expiresAt.Unix(), 10),
+	}, ".")
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    payload + "." + s.signOAuthState(payload),
+		Path:     "/auth/",
+		HttpOnly: true,
+		Secure:   s.isHTTPS(r),
+		SameSite: http.SameSiteLaxMode, // Sent on the top-level redirect back from Discord
+		Expires:  expiresAt,
+	})
+	return state
+}
+
+// consumeOAuthState checks a callback's state against the cookie set by issueOAuthState,
+// returning the path to go to after login. The cookie is cleared whatever the outcome.
+func (s *Server) consumeOAuthState(w http.ResponseWriter, r *http.Request) (string, error) {
+	cookie, err := r.Cookie(oauthStateCookie)
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    "",
+		Path:     "/auth/",
+		HttpOnly: true,
+		Expires:  time.Unix(0, 0), // Expire immediately
+	})
+	if err != nil {
+		return "", errInvalidOAuthState
+	}
+
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 4 {
+		return "", errInvalidOAuthState
+	}
+	payload := strings.Join(parts[:3], ".")
+	if !hmac.Equal([]byte(parts[3]), []byte(s.signOAuthState(payload))) {
+		return "", errInvalidOAuthState
+	}
+
+	state := r.URL.Query().Get("state")
+	if subtle.ConstantTimeCompare([]byte(state), []byte(parts[0])) != 1 {
+		return "", errInvalidOAuthState
+	}
+
+	expires, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil || time.Now().After(time.Unix(expires, 0)) {
+		return "", errInvalidOAuthState
+	}
+	if !s.oauthStates.consume(state, time.Unix(expires, 0)) {
+		return "", errInvalidOAuthState
+	}
+
+	returnTo, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", errInvalidOAuthState
+	}
+	return safeReturnPath(string(returnTo)), nil
+}
+
 // Session management functions
 
-// CreateSession creates a new session for a user
-func (s *Server) createSession(userID string) (string, error) {
-	sessionID := s.generateSessionID()
-	expiresAt := time.Now().Add(30 * 24 * time.Hour) // 30 days
+// sessionTouchInterval limits how often a session's last use is written back to the store
+const sessionTouchInterval = time.Minute
 
-	_, err := s.db.Exec(
-		"INSERT INTO sessions (id, user_id, expires_at) VALUES (?, ?, ?)",
-		sessionID, userID, expiresAt.Format("2006-01-02 15:04:05"),
-	)
+// Session cookie names. Over HTTPS the __Host- prefix makes browsers refuse the cookie unless
+// it is Secure and set by this host for the whole site, so it can't be planted from elsewhere.
+const (
+	sessionCookie       = "session_id"
+	secureSessionCookie = "__Host-session_id"
+)
+
+// CreateSession creates a new session for a user, remembering the browser it was created in.
+// It returns the session token for the cookie; only its digest is stored.
+func (s *Server) createSession(r *http.Request, userID string) (string, error) {
+	token := s.generateSessionID()
+	expiresAt := time.Now().Add(s.config.GetSessionLifetime())
 
-	return sessionID, err
+	err := s.store.CreateSession(r.Context(), hashSessionToken(token), userID, expiresAt,
+		truncateUserAgent(r.UserAgent()), approximateIP(s.clientIP(r)))
+	return token, err
 }
 
-// DeleteSession removes a session from the database
-func (s *Server) deleteSession(sessionID string) error {
-	_, err := s.db.Exec("DELETE FROM sessions WHERE id = ?", sessionID)
-	return err
+// hashSessionToken returns the digest stored in place of a session token, so a copy of the
+// database can't be used to log in
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
-// GetUserFromSession retrieves a user by their session ID
-func (s *Server) getUserFromSession(sessionID string) (*User, error) {
-	var user User
-	err := s.db.QueryRow(`
-		SELECT u.id, u.username, u.avatar, u.discriminator, u.created_at
-		FROM users u
-		JOIN sessions s ON u.id = s.user_id
-		WHERE s.id = ? AND s.expires_at > datetime('now')
-	`, sessionID).Scan(&user.ID, &user.Username, &user.Avatar, &user.Discriminator, &user.CreatedAt)
+// isHTTPS reports whether the request reached the site over HTTPS, directly or through a
+// trusted proxy
+func (s *Server) isHTTPS(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	return s.config != nil && s.config.Server.TrustProxy && strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
 
+// sessionToken returns the session token sent with a request. The plain cookie is still read
+// so sessions started before the site moved to HTTPS carry on working.
+func sessionToken(r *http.Request) (string, error) {
+	cookie, err := r.Cookie(secureSessionCookie)
 	if err != nil {
-		return nil, err
+		cookie, err = r.Cookie(sessionCookie)
+	}
+	if err != nil {
+		return "", err
+	}
+	if cookie.Value == "" {
+		return "", http.ErrNoCookie
+	}
+	return cookie.Value, nil
+}
+
+// setSessionCookie stores a new session token in the browser, replacing any older cookie
+func (s *Server) setSessionCookie(w http.ResponseWriter, r *http.Request, token string) {
+	name := sessionCookie
+	if s.isHTTPS(r) {
+		name = secureSessionCookie
+		// A plain cookie from before the site moved to HTTPS would otherwise linger
+		http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", HttpOnly: true, Expires: time.Unix(0, 0)})
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   s.isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+		Expires:  time.Now().Add(s.config.GetSessionLifetime()),
+	})
+}
+
+// clearSessionCookie removes the session cookie from the browser
+func (s *Server) clearSessionCookie(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		HttpOnly: true,
+		Expires:  time.Unix(0, 0), // Expire immediately
+	})
+	if s.isHTTPS(r) {
+		http.SetCookie(w, &http.Cookie{
+			Name:     secureSessionCookie,
+			Value:    "",
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   true,
+			Expires:  time.Unix(0, 0),
+		})
 	}
+}
 
-	return &user, nil
+// sessionIdleSince returns when a session must have last been used to still be valid, or the
+// zero time if sessions never go idle
+func (s *Server) sessionIdleSince() time.Time {
+	idle := s.config.GetSessionIdleTimeout()
+	if idle == 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(-idle)
 }
 
 // GenerateSessionID generates a random session ID
@@ -56,12 +283,12 @@ func (s *Server) generateSessionID() string {
 
 // GetCurrentUser retrieves the current authenticated user from the request
 func (s *Server) getCurrentUser(r *http.Request) (*User, error) {
-	cookie, err := r.Cookie("session_id")
+	token, err := sessionToken(r)
 	if err != nil {
 		return nil, err
 	}
 
-	return s.getUserFromSession(cookie.Value)
+	return s.store.GetSessionUser(r.Context(), hashSessionToken(token), s.sessionIdleSince())
 }
 
 // Authentication handlers
@@ -82,11 +309,20 @@ func (s *Server) handleAuth(w http.ResponseWriter, r *http.Request, authPath str
 
 // HandleLogin redirects to Discord OAuth
 func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
-	s.discordAuth.RedirectHandler(w, r, "")
+	state := s.issueOAuthState(w, r, safeReturnPath(r.URL.Query().Get("return_to")))
+	s.discordAuth.RedirectHandler(w, r, state)
 }
 
 // HandleCallback processes the OAuth callback from Discord
 func (s *Server) handleCallback(w http.ResponseWriter, r *http.Request) {
+	// Refuse callbacks this browser didn't start, which would log it in as someone else
+	returnTo, err := s.consumeOAuthState(w, r)
+	if err != nil {
+		s.renderError(w, 400, "Authentication Failed", "This login link has expired or was not started from this site",
+			"Please try logging in again.")
+		return
+	}
+
 	// Get the authorization code from URL parameters
 	codes := r.URL.Query()["code"]
 	if len(codes) == 0 {
@@ -101,69 +337,72 @@ func (s *Server) handleCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get user data from Discord
-	userData, err := disgoauth.GetUserData(accessToken)
+	// Get the user's current profile from Discord, refreshing the stored one
+	user, err := s.discord.CurrentUser(r.Context(), accessToken)
 	if err != nil {
 		s.renderError(w, 500, "Authentication Failed", "Failed to get user data", err.Error())
 		return
 	}
 
-	// Create or update user in database
-	user := &User{
-		ID:            userData["id"].(string),
-		Username:      userData["username"].(string),
-		Discriminator: userData["discriminator"].(string),
-	}
-
-	if avatar, ok := userData["avatar"].(string); ok {
-		user.Avatar = avatar
+	err = s.store.UpsertUser(r.Context(), user)
+	if err != nil {
+		s.renderError(w, 500, "Database Error", "Failed to save user", err.Error())
+		return
 	}
 
-	err = s.createOrUpdateUser(user)
+	// Disabled accounts would never get past getCurrentUser, so refuse them here
+	// rather than bouncing them back to Discord forever
+	stored, err := s.store.GetUser(r.Context(), user.ID)
 	if err != nil {
-		s.renderError(w, 500, "Database Error", "Failed to save user", err.Error())
+		s.renderError(w, 500, "Database Error", "Failed to load user", err.Error())
+		return
+	}
+	if stored.DisabledAt != nil {
+		s.renderError(w, 403, "Account Disabled", "Your account has been disabled by an administrator.",
+			"Contact the site operators if you think this is a mistake.")
 		return
 	}
 
+	s.syncManagedGuilds(r.Context(), user.ID, accessToken)
+
 	// Create session
-	sessionID, err := s.createSession(user.ID)
+	token, err := s.createSession(r, user.ID)
 	if err != nil {
 		s.renderError(w, 500, "Session Error", "Failed to create session", err.Error())
 		return
 	}
+	s.audit(r.Context(), newAuditEvent(user.ID, AuditLogin, ""))
 
 	// Set session cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_id",
-		Value:    sessionID,
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   false, // Set to true in production with HTTPS
-		SameSite: http.SameSiteLaxMode,
-		Expires:  time.Now().Add(30 * 24 * time.Hour), // 30 days
-	})
+	s.setSessionCookie(w, r, token)
 
-	// Redirect to dashboard (root)
-	http.Redirect(w, r, "/", http.StatusFound)
+	// Redirect to the page that asked for a login, or the dashboard (root)
+	http.Redirect(w, r, returnTo, http.StatusFound)
 }
 
 // HandleLogout logs out the user and clears their session
 func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	// Logging out is a form post, so it carries the CSRF token and other sites can't do it
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
 	// Get session cookie
-	cookie, err := r.Cookie("session_id")
+	token, err := sessionToken(r)
 	if err == nil {
+		tokenHash := hashSessionToken(token)
+		if user, err := s.store.GetSessionUser(r.Context(), tokenHash, s.sessionIdleSince()); err == nil {
+			s.audit(r.Context(), newAuditEvent(user.ID, AuditLogout, ""))
+		}
+
 		// Delete session from database
-		s.deleteSession(cookie.Value)
+		s.store.DeleteSession(r.Context(), tokenHash)
 	}
 
 	// Clear session cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_id",
-		Value:    "",
-		Path:     "/",
-		HttpOnly: true,
-		Expires:  time.Unix(0, 0), // Expire immediately
-	})
+	s.clearSessionCookie(w, r)
 
 	// Redirect to dashboard (root)
 	http.Redirect(w, r, "/", http.StatusFound)
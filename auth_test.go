@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSafeReturnPath(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"", "/"},
+		{"/", "/"},
+		{"/sessions", "/sessions"},
+		{"/members?short_code=abc", "/members?short_code=abc"},
+		{"/edit?short_code=abc#form", "/edit?short_code=abc#form"},
+
+		// Other hosts
+		{"//evil.com", "/"},
+		{"//evil.com/path", "/"},
+		{"/\\evil.com", "/"},
+		{"\\\\evil.com", "/"},
+		{"https://evil.com", "/"},
+		{"http://evil.com/sessions", "/"},
+		{"evil.com", "/"},
+		{"javascript:alert(1)", "/"},
+		{"/\t/evil.com", "/"},
+
+		// Encoded forms that decode to another host or a scheme
+		{"/%2F%2Fevil.com", "/"},
+		{"/%2fevil.com", "/"},
+		{"/%5Cevil.com", "/"},
+		{"https%3A%2F%2Fevil.com", "/"},
+		{"/https%3A%2F%2Fevil.com", "/https%3A%2F%2Fevil.com"},
+
+		// Login routes would loop
+		{"/auth/login", "/"},
+		{"/auth/logout", "/"},
+		{"/%61uth/logout", "/"},
+	}
+
+	for _, tt := range tests {
+		if got := safeReturnPath(tt.raw); got != tt.want {
+			t.Errorf("safeReturnPath(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+// newStateServer returns a server with just enough set up to issue and check OAuth states
+func newStateServer(secret string) *Server {
+	config := &Config{}
+	config.Client.Secret = secret
+	return &Server{config: config, stateKey: oauthStateKey(config), oauthStates: newOAuthStateLedger()}
+}
+
+// issueState runs issueOAuthState, returning the state and its cookie
+func issueState(s *Server, returnTo string) (string, *http.Cookie) {
+	rec := httptest.NewRecorder()
+	state := s.issueOAuthState(rec, httptest.NewRequest(http.MethodGet, "/auth/login", nil), returnTo)
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == oauthStateCookie {
+			return state, cookie
+		}
+	}
+	return state, nil
+}
+
+// consumeState runs consumeOAuthState for a callback carrying state and, if set, cookieValue
+func consumeState(s *Server, state, cookieValue string) (string, error) {
+	req := httptest.NewRequest(http.MethodGet, "/auth/callback?code=x&state="+state, nil)
+	if cookieValue != "" {
+		req.AddCookie(&http.Cookie{Name: oauthStateCookie, Value: cookieValue})
+	}
+	return s.consumeOAuthState(httptest.NewRecorder(), req)
+}
+
+func TestOAuthState(t *testing.T) {
+	s := newStateServer("secret")
+
+	t.Run("valid", func(t *testing.T) {
+		state, cookie := issueState(s, "/sessions")
+		returnTo, err := consumeState(s, state, cookie.Value)
+		if err != nil || returnTo != "/sessions" {
+			t.Errorf("got %q, %v; want /sessions", returnTo, err)
+		}
+	})
+
+	t.Run("unsafe return path", func(t *testing.T) {
+		state, cookie := issueState(s, "//evil.com")
+		returnTo, err := consumeState(s, state, cookie.Value)
+		if err != nil || returnTo != "/" {
+			t.Errorf("got %q, %v; want /", returnTo, err)
+		}
+	})
+
+	t.Run("replayed", func(t *testing.T) {
+		state, cookie := issueState(s, "/")
+		if _, err := consumeState(s, state, cookie.Value); err != nil {
+			t.Fatalf("first use: %v", err)
+		}
+		if _, err := consumeState(s, state, cookie.Value); !errors.Is(err, errInvalidOAuthState) {
+			t.Errorf("second use: got %v, want errInvalidOAuthState", err)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		// Sign a state that expired a minute ago, as issueOAuthState would have
+		payload := strings.Join([]string{
+			"0123456789abcdef0123456789abcdef",
+			base64.RawURLEncoding.EncodeToString([]byte("/")),
+			strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10),
+		}, ".")
+		cookie := payload + "." + s.signOAuthState(payload)
+		if _, err := consumeState(s, "0123456789abcdef0123456789abcdef", cookie); !errors.Is(err, errInvalidOAuthState) {
+			t.Errorf("got %v, want errInvalidOAuthState", err)
+		}
+	})
+
+	tampered := []struct {
+		name   string
+		tamper func(state, cookie string) (string, string)
+	}{
+		{"missing cookie", func(state, cookie string) (string, string) {
+			return state, ""
+		}},
+		{"state from another login", func(state, cookie string) (string, string) {
+			other, _ := issueState(s, "/")
+			return other, cookie
+		}},
+		{"tampered signature", func(state, cookie string) (string, string) {
+			signature := cookie[strings.LastIndex(cookie, ".")+1:]
+			flipped := "A"
+			if signature[0] == 'A' {
+				flipped = "B"
+			}
+			return state, cookie[:len(cookie)-len(signature)] + flipped + signature[1:]
+		}},
+		{"tampered return path", func(state, cookie string) (string, string) {
+			parts := strings.Split(cookie, ".")
+			parts[1] = base64.RawURLEncoding.EncodeToString([]byte("/admin"))
+			return state, strings.Join(parts, ".")
+		}},
+		{"extended expiry", func(state, cookie string) (string, string) {
+			parts := strings.Split(cookie, ".")
+			parts[2] = strconv.FormatInt(time.Now().Add(24*time.Hour).Unix(), 10)
+			return state, strings.Join(parts, ".")
+		}},
+		{"signed by another server", func(state, cookie string) (string, string) {
+			state, forged := issueState(newStateServer("other secret"), "/")
+			return state, forged.Value
+		}},
+		{"malformed", func(state, cookie string) (string, string) {
+			return state, "not.a.cookie"
+		}},
+	}
+
+	for _, tt := range tampered {
+		t.Run(tt.name, func(t *testing.T) {
+			state, cookie := issueState(s, "/")
+			state, value := tt.tamper(state, cookie.Value)
+			if returnTo, err := consumeState(s, state, value); !errors.Is(err, errInvalidOAuthState) {
+				t.Errorf("got %q, %v; want errInvalidOAuthState", returnTo, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// cacheEntry is a cached redirect lookup. A nil mapping records that the code is not registered.
+type cacheEntry struct {
+	shortCode string
+	mapping   *URLMapping
+	expiresAt time.Time
+}
+
+// RedirectCache is a bounded LRU cache of short code lookups with a TTL, sitting in front of
+// the store on the redirect path. Unknown codes are cached too, for a shorter time, so
+// repeated misses don't reach the database either.
+type RedirectCache struct {
+	mu          sync.Mutex
+	size        int
+	ttl         time.Duration
+	negativeTTL time.Duration
+	entries     map[string]*list.Element
+	order       *list.List // most recently used at the front
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// CacheStats reports how well the redirect cache is doing
+type CacheStats struct {
+	Size    int   `json:"size"`
+	MaxSize int   `json:"max_size"`
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+}
+
+// NewRedirectCache creates a cache holding up to size entries. A size of zero or less disables caching.
+func NewRedirectCache(size int, ttl, negativeTTL time.Duration) *RedirectCache {
+	return &RedirectCache{
+		size:        size,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		entries:     make(map[string]*list.Element),
+		order:       list.New(),
+	}
+}
+
+// Get returns the cached lookup for a short code, reporting whether there was a fresh entry.
+// A hit with a nil mapping means the code is known not to exist.
+func (c *RedirectCache) Get(shortCode string) (*URLMapping, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[shortCode]
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if !time.Now().Before(entry.expiresAt) {
+		c.removeElement(element)
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+	c.hits.Add(1)
+	return entry.mapping, true
+}
+
+// Set caches the lookup for a short code, evicting the least recently used entry when full.
+// Pass a nil mapping to record that the code does not exist.
+func (c *RedirectCache) Set(shortCode string, mapping *URLMapping) {
+	if c.size <= 0 {
+		return
+	}
+
+	ttl := c.ttl
+	if mapping == nil {
+		ttl = c.negativeTTL
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &cacheEntry{shortCode: shortCode, mapping: mapping, expiresAt: time.Now().Add(ttl)}
+	if element, ok := c.entries[shortCode]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[shortCode] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+	}
+}
+
+// Invalidate drops a short code so the next lookup goes to the store
+func (c *RedirectCache) Invalidate(shortCode string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[shortCode]; ok {
+		c.removeElement(element)
+	}
+}
+
+// Clear drops every entry
+func (c *RedirectCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+// Stats returns the cache's current size and hit/miss counters
+func (c *RedirectCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{
+		Size:    c.order.Len(),
+		MaxSize: c.size,
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+	}
+}
+
+// removeElement removes an entry from both the list and the index. Callers must hold c.mu.
+func (c *RedirectCache) removeElement(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*cacheEntry).shortCode)
+}
+
+// lookupRedirect returns the link for a short code whether or not it has expired, going
+// through the redirect cache. It returns ErrNotFound for codes that are not registered.
+func (s *Server) lookupRedirect(ctx context.Context, shortCode string) (*URLMapping, error) {
+	if mapping, ok := s.cache.Get(shortCode); ok {
+		if mapping == nil {
+			return nil, ErrNotFound
+		}
+		return mapping, nil
+	}
+
+	mapping, err := s.store.GetLinkRecord(ctx, shortCode)
+	if errors.Is(err, ErrNotFound) {
+		s.cache.Set(shortCode, nil)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.cache.Set(shortCode, mapping)
+	return mapping, nil
+}
+
+// HandleCacheStatus reports redirect cache statistics as JSON to operators on the local machine
+func (s *Server) handleCacheStatus(w http.ResponseWriter, r *http.Request) {
+	if !isLoopbackRequest(r) {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{"cache": s.cache.Stats()})
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedirectCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewRedirectCache(2, time.Hour, time.Hour)
+	cache.Set("a", &URLMapping{ShortCode: "a"})
+	cache.Set("b", &URLMapping{ShortCode: "b"})
+
+	// Reading a makes b the least recently used
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatal("a should be cached")
+	}
+	cache.Set("c", &URLMapping{ShortCode: "c"})
+
+	if _, ok := cache.Get("b"); ok {
+		t.Error("b should have been evicted")
+	}
+	for _, code := range []string{"a", "c"} {
+		if mapping, ok := cache.Get(code); !ok || mapping.ShortCode != code {
+			t.Errorf("%s should still be cached, got %v, %t", code, mapping, ok)
+		}
+	}
+	if size := cache.Stats().Size; size != 2 {
+		t.Errorf("cache holds %d entries, want 2", size)
+	}
+}
+
+func TestRedirectCachePositiveTTL(t *testing.T) {
+	cache := NewRedirectCache(10, 20*time.Millisecond, time.Hour)
+	cache.Set("a", &URLMapping{ShortCode: "a"})
+
+	if mapping, ok := cache.Get("a"); !ok || mapping == nil {
+		t.Fatal("a should be cached before its TTL passes")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := cache.Get("a"); ok {
+		t.Error("a should have expired")
+	}
+	if size := cache.Stats().Size; size != 0 {
+		t.Errorf("expired entry was kept, cache holds %d entries", size)
+	}
+}
+
+func TestRedirectCacheNegativeTTL(t *testing.T) {
+	cache := NewRedirectCache(10, time.Hour, 20*time.Millisecond)
+	cache.Set("missing", nil)
+	cache.Set("a", &URLMapping{ShortCode: "a"})
+
+	if mapping, ok := cache.Get("missing"); !ok || mapping != nil {
+		t.Fatalf("missing should be cached as not found, got %v, %t", mapping, ok)
+	}
+
+	// Misses expire on their own, shorter TTL
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := cache.Get("missing"); ok {
+		t.Error("missing should have expired")
+	}
+	if _, ok := cache.Get("a"); !ok {
+		t.Error("a should outlive the negative TTL")
+	}
+
+	// A zero negative TTL turns off caching misses
+	cache = NewRedirectCache(10, time.Hour, 0)
+	cache.Set("missing", nil)
+	if _, ok := cache.Get("missing"); ok {
+		t.Error("misses should not be cached with a zero negative TTL")
+	}
+}
+
+// newTestServer starts a server on store with user u1 logged in, admin u9, and Discord
+// resolving every invite to guild g1
+func newTestServer(t testing.TB, store Store) *Server {
+	t.Helper()
+
+	discord := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code := strings.TrimPrefix(r.URL.Path, "/invites/")
+		fmt.Fprintf(w, `{"code": %q, "guild": {"id": "g1", "name": "Guild One"}}`, code)
+	}))
+	t.Cleanup(discord.Close)
+
+	config := &Config{}
+	config.Client.ID = "1"
+	config.Client.Secret = "secret"
+	config.Server.Domain = "drop-reg.cc"
+	config.RateLimits.Disabled = true
+	config.Discord.APIBaseURL = discord.URL
+	config.Admin.UserIDs = []string{"u9"}
+
+	s, err := InitServer(store, config)
+	if err != nil {
+		t.Fatalf("InitServer: %v", err)
+	}
+
+	ctx := context.Background()
+	for _, id := range []string{"u1", "u9"} {
+		if err := store.UpsertUser(ctx, &User{ID: id, Username: id}); err != nil {
+			t.Fatalf("UpsertUser: %v", err)
+		}
+		if err := store.CreateSession(ctx, hashSessionToken("session-"+id), id, time.Now().Add(time.Hour), "", ""); err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+	}
+	return s
+}
+
+// postForm submits a dashboard form as userID, with the session's CSRF token
+func postForm(s *Server, userID, path string, form url.Values) *httptest.ResponseRecorder {
+	cookie := &http.Cookie{Name: "session_id", Value: "session-" + userID}
+
+	// The CSRF token is derived from the session, so any request carrying it will do
+	tokenRequest := httptest.NewRequest(http.MethodGet, "/", nil)
+	tokenRequest.AddCookie(cookie)
+	form.Set(csrfFormField, s.csrfToken(tokenRequest))
+
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Host = "drop-reg.cc"
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(cookie)
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestLookupRedirectInvalidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		userID string
+		path   string
+		form   url.Values
+		check  func(t *testing.T, mapping *URLMapping, err error)
+	}{
+		{
+			name:   "edit",
+			userID: "u1",
+			path:   "/edit",
+			form:   url.Values{"short_code": {"abc"}, "discord_url": {"https://discord.gg/new"}},
+			check: func(t *testing.T, mapping *URLMapping, err error) {
+				if err != nil || mapping.DiscordURL != "https://discord.gg/new" {
+					t.Errorf("lookup after edit = %v, %v; want the new URL", mapping, err)
+				}
+			},
+		},
+		{
+			name:   "delete",
+			userID: "u1",
+			path:   "/delete",
+			form:   url.Values{"short_code": {"abc"}},
+			check: func(t *testing.T, mapping *URLMapping, err error) {
+				if !errors.Is(err, ErrNotFound) {
+					t.Errorf("lookup after delete = %v, %v; want ErrNotFound", mapping, err)
+				}
+			},
+		},
+		{
+			name:   "disable",
+			userID: "u9",
+			path:   "/admin/links/disable",
+			form:   url.Values{"short_code": {"abc"}},
+			check: func(t *testing.T, mapping *URLMapping, err error) {
+				if err != nil || mapping.DisabledAt == nil {
+					t.Errorf("lookup after disable = %v, %v; want a disabled link", mapping, err)
+				}
+			},
+		},
+		{
+			name:   "disable owner",
+			userID: "u9",
+			path:   "/admin/users/disable",
+			form:   url.Values{"user_id": {"u1"}},
+			check: func(t *testing.T, mapping *URLMapping, err error) {
+				if err != nil || mapping.DisabledAt == nil {
+					t.Errorf("lookup after disabling the owner = %v, %v; want a disabled link", mapping, err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := NewMemoryStore()
+			s := newTestServer(t, store)
+
+			if err := store.CreateLink(ctx, "abc", "https://discord.gg/old", "u1", LinkExpiry{}, nil); err != nil {
+				t.Fatalf("CreateLink: %v", err)
+			}
+			if _, err := s.lookupRedirect(ctx, "abc"); err != nil {
+				t.Fatalf("lookupRedirect: %v", err)
+			}
+			if _, ok := s.cache.Get("abc"); !ok {
+				t.Fatal("lookup should have cached abc")
+			}
+
+			rec := postForm(s, tt.userID, tt.path, tt.form)
+			// A lost session would also redirect, to the login page
+			if rec.Code != http.StatusFound || strings.HasPrefix(rec.Header().Get("Location"), "/auth/") {
+				t.Fatalf("POST %s = %d: %s", tt.path, rec.Code, rec.Body.String())
+			}
+			if _, ok := s.cache.Get("abc"); ok {
+				t.Errorf("%s left abc in the cache", tt.name)
+			}
+
+			mapping, err := s.lookupRedirect(ctx, "abc")
+			tt.check(t, mapping, err)
+		})
+	}
+}
+
+func BenchmarkLookupRedirect(b *testing.B) {
+	store, err := OpenSQLiteStore(filepath.Join(b.TempDir(), "bench.db"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer store.Close()
+	if _, err := store.Migrate(); err != nil {
+		b.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if err := store.UpsertUser(ctx, &User{ID: "u1", Username: "u1"}); err != nil {
+		b.Fatal(err)
+	}
+	const links = 100
+	for i := 0; i < links; i++ {
+		code := fmt.Sprintf("c%d", i)
+		if err := store.CreateLink(ctx, code, "https://discord.gg/"+code, "u1", LinkExpiry{}, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	for _, bench := range []struct {
+		name      string
+		cacheSize int
+	}{
+		{"uncached", 0},
+		{"cached", links},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			s := &Server{store: store, cache: NewRedirectCache(bench.cacheSize, time.Hour, time.Hour)}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := s.lookupRedirect(ctx, fmt.Sprintf("c%d", i%links)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
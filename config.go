@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
@@ -36,6 +37,79 @@ func (c *Config) GetRedirectURI() string {
 	return "http://localhost:8080/auth/callback"
 }
 
+// IsAdmin reports whether a Discord user ID is listed as an admin
+func (c *Config) IsAdmin(userID string) bool {
+	for _, adminID := range c.Admin.UserIDs {
+		if adminID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// IsModerator reports whether a Discord user ID may work the abuse report queue. Admins are
+// always moderators.
+func (c *Config) IsModerator(userID string) bool {
+	if c.IsAdmin(userID) {
+		return true
+	}
+	for _, moderatorID := range c.Admin.ModeratorIDs {
+		if moderatorID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// GuildVerificationEnabled reports whether registrants are checked against the guilds they manage
+func (c *Config) GuildVerificationEnabled() bool {
+	return c.Discord.VerifyGuilds || c.Discord.RequireVerified
+}
+
+// Redirect modes, selecting how short links are addressed
+const (
+	RedirectModeSubdomain = "subdomain"
+	RedirectModePath      = "path"
+	RedirectModeBoth      = "both"
+)
+
+// GetTrustedProxyHops returns how many reverse proxies append to X-Forwarded-For, defaulting to 1
+func (c *Config) GetTrustedProxyHops() int {
+	if c.Server.TrustedProxyHops <= 0 {
+		return 1
+	}
+	return c.Server.TrustedProxyHops
+}
+
+// GetRedirectMode returns how short links are addressed, defaulting to subdomains
+func (c *Config) GetRedirectMode() string {
+	if c.Server.RedirectMode == "" {
+		return RedirectModeSubdomain
+	}
+	return strings.ToLower(c.Server.RedirectMode)
+}
+
+// validateRedirectMode fails for redirect modes other than the known ones
+func (c *Config) validateRedirectMode() error {
+	switch c.GetRedirectMode() {
+	case RedirectModeSubdomain, RedirectModePath, RedirectModeBoth:
+		return nil
+	}
+	return fmt.Errorf("unknown redirect_mode %q, expected subdomain, path or both", c.Server.RedirectMode)
+}
+
+// SubdomainRedirects reports whether <code>.domain redirects to the code's invite
+func (c *Config) SubdomainRedirects() bool {
+	mode := c.GetRedirectMode()
+	return mode == RedirectModeSubdomain || mode == RedirectModeBoth
+}
+
+// PathRedirects reports whether domain/<code> redirects to the code's invite
+func (c *Config) PathRedirects() bool {
+	mode := c.GetRedirectMode()
+	return mode == RedirectModePath || mode == RedirectModeBoth
+}
+
 // GetPort returns the server port, defaulting to 8080 if not set
 func (c *Config) GetPort() int64 {
 	if c.Server.Port == 0 {
@@ -43,3 +117,162 @@ func (c *Config) GetPort() int64 {
 	}
 	return c.Server.Port
 }
+
+// GetDatabasePath returns the SQLite database file, defaulting to drop-reg.db
+func (c *Config) GetDatabasePath() string {
+	if c.Server.DatabasePath == "" {
+		return "drop-reg.db"
+	}
+	return c.Server.DatabasePath
+}
+
+// GetSessionCleanupInterval returns how often expired sessions are purged, defaulting to 1 hour
+func (c *Config) GetSessionCleanupInterval() time.Duration {
+	if c.Jobs.SessionCleanupInterval == 0 {
+		return time.Hour
+	}
+	return c.Jobs.SessionCleanupInterval
+}
+
+// GetSessionLifetime returns how long a login lasts, defaulting to 30 days
+func (c *Config) GetSessionLifetime() time.Duration {
+	if c.Sessions.Lifetime <= 0 {
+		return 30 * 24 * time.Hour
+	}
+	return c.Sessions.Lifetime
+}
+
+// GetSessionIdleTimeout returns how long an unused session lasts, defaulting to 7 days.
+// Zero means sessions never go idle.
+func (c *Config) GetSessionIdleTimeout() time.Duration {
+	if c.Sessions.IdleTimeout == 0 {
+		return 7 * 24 * time.Hour
+	}
+	if c.Sessions.IdleTimeout < 0 {
+		return 0
+	}
+	return c.Sessions.IdleTimeout
+}
+
+// GetLinkCleanupInterval returns how often long-expired links are purged, defaulting to 6 hours
+func (c *Config) GetLinkCleanupInterval() time.Duration {
+	if c.Jobs.LinkCleanupInterval == 0 {
+		return 6 * time.Hour
+	}
+	return c.Jobs.LinkCleanupInterval
+}
+
+// GetExpiredLinkGracePeriod returns how long an expired link is kept for renewal before
+// it is purged, defaulting to 30 days
+func (c *Config) GetExpiredLinkGracePeriod() time.Duration {
+	if c.Jobs.ExpiredLinkGracePeriod == 0 {
+		return 30 * 24 * time.Hour
+	}
+	return c.Jobs.ExpiredLinkGracePeriod
+}
+
+// GetInviteCheckInterval returns how often invites are checked for health, defaulting to 1 hour
+func (c *Config) GetInviteCheckInterval() time.Duration {
+	if c.Jobs.InviteCheckInterval == 0 {
+		return time.Hour
+	}
+	return c.Jobs.InviteCheckInterval
+}
+
+// GetInviteRecheckAge returns how long a link's health check result is trusted, defaulting to 24 hours
+func (c *Config) GetInviteRecheckAge() time.Duration {
+	if c.Jobs.InviteRecheckAge == 0 {
+		return 24 * time.Hour
+	}
+	return c.Jobs.InviteRecheckAge
+}
+
+// GetShortCodeMinLength returns the shortest short code that may be registered, defaulting to 1
+func (c *Config) GetShortCodeMinLength() int {
+	if c.ShortCodes.MinLength <= 0 {
+		return 1
+	}
+	return c.ShortCodes.MinLength
+}
+
+// GetShortCodeMaxLength returns the longest short code that may be registered, defaulting to 5.
+// Codes are DNS labels, so it is capped at 63.
+func (c *Config) GetShortCodeMaxLength() int {
+	if c.ShortCodes.MaxLength <= 0 {
+		return 5
+	}
+	if c.ShortCodes.MaxLength > 63 {
+		return 63
+	}
+	return c.ShortCodes.MaxLength
+}
+
+// GetDiscordAPIBaseURL returns the Discord REST API base URL, defaulting to Discord's v10 API
+func (c *Config) GetDiscordAPIBaseURL() string {
+	if c.Discord.APIBaseURL == "" {
+		return "https://discord.com/api/v10"
+	}
+	return c.Discord.APIBaseURL
+}
+
+// GetCacheSize returns how many short codes the redirect cache holds, defaulting to 10000
+func (c *Config) GetCacheSize() int {
+	if c.Cache.Size == 0 {
+		return 10000
+	}
+	return c.Cache.Size
+}
+
+// GetCacheTTL returns how long a registered code stays cached, defaulting to 5 minutes
+func (c *Config) GetCacheTTL() time.Duration {
+	if c.Cache.TTL == 0 {
+		return 5 * time.Minute
+	}
+	return c.Cache.TTL
+}
+
+// GetCacheNegativeTTL returns how long an unknown code stays cached, defaulting to 30 seconds
+func (c *Config) GetCacheNegativeTTL() time.Duration {
+	if c.Cache.NegativeTTL == 0 {
+		return 30 * time.Second
+	}
+	return c.Cache.NegativeTTL
+}
+
+// Default rate limits used for any rule not set in the config
+var defaultRateLimits = map[string]RateLimitRule{
+	LimitRegister: {PerMinute: 5, Burst: 5},
+	LimitLogin:    {PerMinute: 20, Burst: 10},
+	LimitRedirect: {PerMinute: 120, Burst: 60},
+	LimitAPI:      {PerMinute: 60, Burst: 30},
+	LimitNotFound: {PerMinute: 10, Burst: 10},
+	LimitReport:   {PerMinute: 1, Burst: 3},
+}
+
+// GetRateLimitRule returns the configured rate limit for a limiter, falling back to its default
+func (c *Config) GetRateLimitRule(name string) RateLimitRule {
+	var rule RateLimitRule
+	switch name {
+	case LimitRegister:
+		rule = c.RateLimits.Register
+	case LimitLogin:
+		rule = c.RateLimits.Login
+	case LimitRedirect:
+		rule = c.RateLimits.Redirect
+	case LimitAPI:
+		rule = c.RateLimits.API
+	case LimitNotFound:
+		rule = c.RateLimits.NotFound
+	case LimitReport:
+		rule = c.RateLimits.Report
+	}
+
+	defaults := defaultRateLimits[name]
+	if rule.PerMinute == 0 {
+		rule.PerMinute = defaults.PerMinute
+	}
+	if rule.Burst == 0 {
+		rule.Burst = defaults.Burst
+	}
+	return rule
+}
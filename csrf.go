@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+// Where a form post carries its CSRF token. Scripts can send the header instead of a field.
+const (
+	csrfFormField = "csrf_token"
+	csrfHeader    = "X-CSRF-Token"
+)
+
+// csrfKey derives the key that ties CSRF tokens to sessions from the client secret, which only
+// the server knows
+func csrfKey(config *Config) []byte {
+	key := sha256.Sum256([]byte("drop-reg csrf:" + config.Client.Secret))
+	return key[:]
+}
+
+// csrfToken returns the token forms must post back for the visitor's session, or "" if they
+// aren't logged in. It is derived from the session, so it changes on every login and can't be
+// guessed by another site.
+func (s *Server) csrfToken(r *http.Request) string {
+	token, err := sessionToken(r)
+	if err != nil {
+		return ""
+	}
+	mac := hmac.New(sha256.New, s.csrfKey)
+	mac.Write([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// checkCSRF rejects a state-changing request made with the session cookie unless it carries
+// the session's CSRF token, reporting whether the request may continue
+func (s *Server) checkCSRF(w http.ResponseWriter, r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+
+	// The API authenticates with bearer tokens, which browsers never attach on their own
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		return true
+	}
+
+	// Without a session there is nothing to act on behalf of
+	expected := s.csrfToken(r)
+	if expected == "" {
+		return true
+	}
+
+	token := r.Header.Get(csrfHeader)
+	if token == "" {
+		token = r.FormValue(csrfFormField)
+	}
+	if hmac.Equal([]byte(token), []byte(expected)) {
+		return true
+	}
+
+	s.renderError(w, http.StatusForbidden, "Form Expired",
+		"This form was submitted from an expired page or from another site.",
+		"Go back, refresh the page and try again. If you logged in again since opening it, the old page can't be used.")
+	return false
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// sessionRequest builds a request carrying userID's session cookie from newTestServer, with
+// form as its body when it isn't nil
+func sessionRequest(method, path, userID string, form url.Values) *http.Request {
+	body := ""
+	if form != nil {
+		body = form.Encode()
+	}
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Host = "drop-reg.cc"
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if userID != "" {
+		req.AddCookie(&http.Cookie{Name: "session_id", Value: "session-" + userID})
+	}
+	return req
+}
+
+// isFormExpired reports whether a response is the page checkCSRF shows when it refuses a post
+func isFormExpired(rec *httptest.ResponseRecorder) bool {
+	return rec.Code == http.StatusForbidden && strings.Contains(rec.Body.String(), "Form Expired")
+}
+
+func TestCheckCSRF(t *testing.T) {
+	store := NewMemoryStore()
+	s := newTestServer(t, store)
+	if err := store.CreateLink(context.Background(), "abc", "https://discord.gg/abc", "u1", LinkExpiry{}, nil); err != nil {
+		t.Fatalf("CreateLink: %v", err)
+	}
+
+	tokenFor := func(userID string) string {
+		return s.csrfToken(sessionRequest(http.MethodGet, "/", userID, nil))
+	}
+
+	tests := []struct {
+		name    string
+		req     *http.Request
+		refused bool
+	}{
+		{
+			name:    "missing token",
+			req:     sessionRequest(http.MethodPost, "/renew", "u1", url.Values{"short_code": {"abc"}}),
+			refused: true,
+		},
+		{
+			name:    "wrong token",
+			req:     sessionRequest(http.MethodPost, "/renew", "u1", url.Values{"short_code": {"abc"}, csrfFormField: {"wrong"}}),
+			refused: true,
+		},
+		{
+			name:    "token from another session",
+			req:     sessionRequest(http.MethodPost, "/renew", "u1", url.Values{"short_code": {"abc"}, csrfFormField: {tokenFor("u9")}}),
+			refused: true,
+		},
+		{
+			name: "form token",
+			req:  sessionRequest(http.MethodPost, "/renew", "u1", url.Values{"short_code": {"abc"}, csrfFormField: {tokenFor("u1")}}),
+		},
+		{
+			name: "header token",
+			req: func() *http.Request {
+				req := sessionRequest(http.MethodPost, "/renew", "u1", url.Values{"short_code": {"abc"}})
+				req.Header.Set(csrfHeader, tokenFor("u1"))
+				return req
+			}(),
+		},
+		{
+			name: "GET",
+			req:  sessionRequest(http.MethodGet, "/", "u1", nil),
+		},
+		{
+			name: "HEAD",
+			req:  sessionRequest(http.MethodHead, "/", "u1", nil),
+		},
+		{
+			// The API authenticates with bearer tokens, so a stray cookie doesn't need a token
+			name: "API",
+			req:  sessionRequest(http.MethodPost, "/api/v1/links", "u1", url.Values{}),
+		},
+		{
+			name: "no session",
+			req:  sessionRequest(http.MethodPost, "/renew", "", url.Values{"short_code": {"abc"}}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, tt.req)
+			if refused := isFormExpired(rec); refused != tt.refused {
+				t.Errorf("refused = %t, want %t (status %d)", refused, tt.refused, rec.Code)
+			}
+		})
+	}
+}
+
+func TestLogoutRequiresCSRFPost(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	s := newTestServer(t, store)
+	loggedIn := func() bool {
+		_, err := store.GetSessionUser(ctx, hashSessionToken("session-u1"), s.sessionIdleSince())
+		return err == nil
+	}
+
+	// A link or image on another site can't log anyone out
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, sessionRequest(http.MethodGet, "/auth/logout", "u1", nil))
+	if rec.Code != http.StatusMethodNotAllowed || !loggedIn() {
+		t.Errorf("GET /auth/logout = %d, logged in %t; want 405 and still logged in", rec.Code, loggedIn())
+	}
+
+	// Nor can a form posted from another site
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, sessionRequest(http.MethodPost, "/auth/logout", "u1", url.Values{}))
+	if !isFormExpired(rec) || !loggedIn() {
+		t.Errorf("POST /auth/logout without a token = %d, logged in %t; want Form Expired and still logged in", rec.Code, loggedIn())
+	}
+
+	rec = postForm(s, "u1", "/auth/logout", url.Values{})
+	if rec.Code != http.StatusFound || loggedIn() {
+		t.Errorf("POST /auth/logout = %d, logged in %t; want a redirect and logged out", rec.Code, loggedIn())
+	}
+}
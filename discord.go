@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors returned when resolving a Discord invite
+var (
+	// ErrInviteInvalid is returned when Discord does not recognise an invite or it has expired
+	ErrInviteInvalid = errors.New("This Discord invite is invalid or has expired")
+	// ErrDiscordUnavailable is returned when Discord could not be asked about an invite
+	ErrDiscordUnavailable = errors.New("Could not reach Discord to check the invite, please try again")
+)
+
+// DiscordInvite is what Discord reports about a server invite
+type DiscordInvite struct {
+	Code      string
+	GuildID   string
+	GuildName string
+	ExpiresAt *time.Time
+
+	// Verified is set once the user registering the invite is known to manage its guild
+	Verified bool
+}
+
+// dbExpiresAt returns the invite expiry in the format stored in url_mappings.invite_expires_at
+func (i *DiscordInvite) dbExpiresAt() *string {
+	if i == nil || i.ExpiresAt == nil {
+		return nil
+	}
+	value := dbTime(*i.ExpiresAt)
+	return &value
+}
+
+// dbVerifiedAt returns the verification time to store in url_mappings.verified_at, or nil
+// when the invite wasn't verified
+func (i *DiscordInvite) dbVerifiedAt() *string {
+	if i == nil || !i.Verified {
+		return nil
+	}
+	value := dbTime(time.Now())
+	return &value
+}
+
+// guild returns the guild ID and name to store, or nils when there is no invite
+func (i *DiscordInvite) guild() (*string, *string) {
+	if i == nil {
+		return nil, nil
+	}
+	return &i.GuildID, &i.GuildName
+}
+
+// health returns the health state to store for a link whose invite was just resolved,
+// or nils when there is no invite
+func (i *DiscordInvite) health() (*string, *string) {
+	if i == nil {
+		return nil, nil
+	}
+	health, checkedAt := LinkHealthy, dbTime(time.Now())
+	return &health, &checkedAt
+}
+
+// DiscordClient talks to Discord's public REST API
+type DiscordClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewDiscordClient creates a client for the Discord API at baseURL
+func NewDiscordClient(baseURL string) *DiscordClient {
+	return &DiscordClient{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// discordInviteResponse is the subset of Discord's invite object we use
+type discordInviteResponse struct {
+	Code      string     `json:"code"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	Guild     *struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"guild"`
+}
+
+// ResolveInvite looks an invite code up with Discord, failing with ErrInviteInvalid if it
+// does not exist, has expired or is not for a server
+func (c *DiscordClient) ResolveInvite(ctx context.Context, code string) (*DiscordInvite, error) {
+	endpoint := fmt.Sprintf("%s/invites/%s?with_expiration=true", c.baseURL, url.PathEscape(code))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiscordUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrInviteInvalid
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: Discord returned %s", ErrDiscordUnavailable, resp.Status)
+	}
+
+	var body discordInviteResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiscordUnavailable, err)
+	}
+
+	// Group DM invites have no guild and can't be shared as a server link
+	if body.Guild == nil {
+		return nil, ErrInviteInvalid
+	}
+	if body.ExpiresAt != nil && !body.ExpiresAt.After(time.Now()) {
+		return nil, ErrInviteInvalid
+	}
+
+	return &DiscordInvite{
+		Code:      body.Code,
+		GuildID:   body.Guild.ID,
+		GuildName: body.Guild.Name,
+		ExpiresAt: body.ExpiresAt,
+	}, nil
+}
+
+// Guild permission bits that let a member manage the server
+const (
+	permissionAdministrator = 1 << 3
+	permissionManageGuild   = 1 << 5
+)
+
+// discordGuildResponse is the subset of Discord's partial guild object we use
+type discordGuildResponse struct {
+	ID          string `json:"id"`
+	Owner       bool   `json:"owner"`
+	Permissions string `json:"permissions"`
+}
+
+// ManagedGuilds returns the IDs of the guilds the holder of an OAuth access token owns or has
+// Manage Server (or Administrator) in. The token needs the guilds scope.
+func (c *DiscordClient) ManagedGuilds(ctx context.Context, accessToken string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/users/@me/guilds", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiscordUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: Discord returned %s", ErrDiscordUnavailable, resp.Status)
+	}
+
+	var guilds []discordGuildResponse
+	if err := json.NewDecoder(resp.Body).Decode(&guilds); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiscordUnavailable, err)
+	}
+
+	var managed []string
+	for _, guild := range guilds {
+		permissions, _ := strconv.ParseUint(guild.Permissions, 10, 64)
+		if guild.Owner || permissions&(permissionAdministrator|permissionManageGuild) != 0 {
+			managed = append(managed, guild.ID)
+		}
+	}
+	return managed, nil
+}
+
+// discordUserResponse is the subset of Discord's user object we use
+type discordUserResponse struct {
+	ID            string  `json:"id"`
+	Username      string  `json:"username"`
+	GlobalName    *string `json:"global_name"`
+	Discriminator string  `json:"discriminator"`
+	Avatar        *string `json:"avatar"`
+}
+
+// CurrentUser returns the profile of the holder of an OAuth access token. The token needs the
+// identify scope.
+func (c *DiscordClient) CurrentUser(ctx context.Context, accessToken string) (*User, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/users/@me", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiscordUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: Discord returned %s", ErrDiscordUnavailable, resp.Status)
+	}
+
+	var body discordUserResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiscordUnavailable, err)
+	}
+	if body.ID == "" || body.Username == "" {
+		return nil, fmt.Errorf("%w: Discord returned a user without an ID or username", ErrDiscordUnavailable)
+	}
+
+	user := &User{
+		ID:            body.ID,
+		Username:      body.Username,
+		Discriminator: body.Discriminator,
+	}
+	if body.GlobalName != nil {
+		user.GlobalName = *body.GlobalName
+	}
+	if body.Avatar != nil {
+		user.Avatar = *body.Avatar
+	}
+	return user, nil
+}
+
+// discordCDN serves user avatars
+const discordCDN = "https://cdn.discordapp.com"
+
+// DisplayName returns the name Discord shows for a user: their display name if they set one,
+// otherwise their username
+func (u User) DisplayName() string {
+	if u.GlobalName != "" {
+		return u.GlobalName
+	}
+	return u.Username
+}
+
+// Handle returns the name that uniquely identifies a user on Discord, "@name" for accounts on
+// unique usernames and "name#1234" for those that still have a discriminator
+func (u User) Handle() string {
+	if u.Discriminator == "" || u.Discriminator == "0" {
+		return "@" + u.Username
+	}
+	return u.Username + "#" + u.Discriminator
+}
+
+// AvatarURL returns the user's avatar image, or the default avatar Discord gives them if they
+// haven't uploaded one
+func (u User) AvatarURL() string {
+	if u.Avatar != "" {
+		// Hashes of animated avatars start with a_
+		format := "png"
+		if strings.HasPrefix(u.Avatar, "a_") {
+			format = "gif"
+		}
+		return fmt.Sprintf("%s/avatars/%s/%s.%s?size=128", discordCDN, url.PathEscape(u.ID), url.PathEscape(u.Avatar), format)
+	}
+
+	// Default avatars are picked from the user ID, or the discriminator on legacy accounts
+	var index uint64
+	if discriminator, err := strconv.ParseUint(u.Discriminator, 10, 64); err == nil && discriminator != 0 {
+		index = discriminator % 5
+	} else if id, err := strconv.ParseUint(u.ID, 10, 64); err == nil {
+		index = (id >> 22) % 6
+	}
+	return fmt.Sprintf("%s/embed/avatars/%d.png", discordCDN, index)
+}
+
+// resolveInvite checks a Discord invite URL against Discord
+func (s *Server) resolveInvite(ctx context.Context, discordURL string) (*DiscordInvite, error) {
+	invite, err := parseDiscordInviteURL(discordURL)
+	if err != nil {
+		return nil, ErrInviteInvalid
+	}
+	return s.discord.ResolveInvite(ctx, invite.Code)
+}
+
+// inviteError returns the HTTP status and user-facing message for an invite resolution error,
+// logging failures that weren't the user's fault
+func inviteError(err error) (int, string) {
+	if errors.Is(err, ErrInviteInvalid) {
+		return http.StatusBadRequest, ErrInviteInvalid.Error()
+	}
+	log.Printf("Discord invite lookup failed: %v", err)
+	return http.StatusBadGateway, ErrDiscordUnavailable.Error()
+}
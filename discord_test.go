@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestResolveInvite(t *testing.T) {
+	future := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	past := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+
+	discord := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("with_expiration") != "true" {
+			t.Errorf("%s requested without with_expiration", r.URL)
+		}
+
+		switch r.URL.Path {
+		case "/invites/valid":
+			fmt.Fprintf(w, `{"code": "valid", "expires_at": %q, "guild": {"id": "123", "name": "Test Guild"}}`, future.Format(time.RFC3339))
+		case "/invites/permanent":
+			fmt.Fprint(w, `{"code": "permanent", "expires_at": null, "guild": {"id": "456", "name": "Forever"}}`)
+		case "/invites/expired":
+			fmt.Fprintf(w, `{"code": "expired", "expires_at": %q, "guild": {"id": "123", "name": "Test Guild"}}`, past.Format(time.RFC3339))
+		case "/invites/groupdm":
+			fmt.Fprint(w, `{"code": "groupdm", "expires_at": null, "channel": {"id": "789", "type": 3}}`)
+		case "/invites/broken":
+			http.Error(w, `{"message": "oops"}`, http.StatusInternalServerError)
+		default:
+			http.Error(w, `{"message": "Unknown Invite", "code": 10006}`, http.StatusNotFound)
+		}
+	}))
+	defer discord.Close()
+
+	client := NewDiscordClient(discord.URL + "/")
+
+	tests := []struct {
+		code    string
+		want    *DiscordInvite
+		wantErr error
+	}{
+		{code: "valid", want: &DiscordInvite{Code: "valid", GuildID: "123", GuildName: "Test Guild", ExpiresAt: &future}},
+		{code: "permanent", want: &DiscordInvite{Code: "permanent", GuildID: "456", GuildName: "Forever"}},
+		{code: "unknown", wantErr: ErrInviteInvalid},
+		{code: "expired", wantErr: ErrInviteInvalid},
+		{code: "groupdm", wantErr: ErrInviteInvalid},
+		{code: "broken", wantErr: ErrDiscordUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			invite, err := client.ResolveInvite(context.Background(), tt.code)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("got %+v, %v; want error %v", invite, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveInvite: %v", err)
+			}
+
+			if invite.Code != tt.want.Code || invite.GuildID != tt.want.GuildID || invite.GuildName != tt.want.GuildName {
+				t.Errorf("got %+v, want %+v", invite, tt.want)
+			}
+			switch {
+			case tt.want.ExpiresAt == nil && invite.ExpiresAt != nil:
+				t.Errorf("ExpiresAt = %v, want nil", invite.ExpiresAt)
+			case tt.want.ExpiresAt != nil && (invite.ExpiresAt == nil || !invite.ExpiresAt.Equal(*tt.want.ExpiresAt)):
+				t.Errorf("ExpiresAt = %v, want %v", invite.ExpiresAt, tt.want.ExpiresAt)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExpiryPresetDays are the relative lifetimes offered on the registration and dashboard forms
+var ExpiryPresetDays = []int{7, 30, 90}
+
+// DefaultRenewalDays is used when renewing a link that was given a fixed expiry date
+const DefaultRenewalDays = 30
+
+// MaxExpiryDays caps how far in the future a fixed expiry date may be set
+const MaxExpiryDays = 365 * 5
+
+// LinkExpiry describes when a link stops redirecting
+type LinkExpiry struct {
+	// ExpiresAt is nil for links that never expire
+	ExpiresAt *time.Time
+	// Days is the relative lifetime chosen by the owner, used again on renewal.
+	// It is nil for links that never expire or were given a fixed date.
+	Days *int
+}
+
+// dbExpiresAt returns the expiry in the format stored in url_mappings.expires_at
+func (e LinkExpiry) dbExpiresAt() *string {
+	if e.ExpiresAt == nil {
+		return nil
+	}
+	value := dbTime(*e.ExpiresAt)
+	return &value
+}
+
+// isActive reports whether a link has not yet expired
+func isActive(mapping *URLMapping) bool {
+	if mapping.ExpiresAt == nil {
+		return true
+	}
+	expiresAt, err := parseTimestamp(*mapping.ExpiresAt)
+	return err != nil || expiresAt.After(time.Now())
+}
+
+// expiryInDays returns a relative expiry starting now
+func expiryInDays(days int) LinkExpiry {
+	expiresAt := time.Now().UTC().Add(time.Duration(days) * 24 * time.Hour)
+	return LinkExpiry{ExpiresAt: &expiresAt, Days: &days}
+}
+
+// parseLinkExpiry parses the expiry form fields: "never", a preset day count, or "date"
+// together with a YYYY-MM-DD expiry_date
+func parseLinkExpiry(choice, date string) (LinkExpiry, error) {
+	choice = strings.TrimSpace(choice)
+	if choice == "" || choice == "never" {
+		return LinkExpiry{}, nil
+	}
+
+	if choice == "date" {
+		day, err := time.Parse("2006-01-02", strings.TrimSpace(date))
+		if err != nil {
+			return LinkExpiry{}, errors.New("Expiry date must be a valid date")
+		}
+
+		// The link stays active for the whole of the chosen day
+		expiresAt := day.Add(24*time.Hour - time.Second).UTC()
+		if !expiresAt.After(time.Now()) {
+			return LinkExpiry{}, errors.New("Expiry date must be in the future")
+		}
+		if expiresAt.After(time.Now().Add(MaxExpiryDays * 24 * time.Hour)) {
+			return LinkExpiry{}, fmt.Errorf("Expiry date must be within %d days", MaxExpiryDays)
+		}
+		return LinkExpiry{ExpiresAt: &expiresAt}, nil
+	}
+
+	days, err := strconv.Atoi(choice)
+	if err == nil {
+		for _, preset := range ExpiryPresetDays {
+			if days == preset {
+				return expiryInDays(days), nil
+			}
+		}
+	}
+
+	return LinkExpiry{}, errors.New("Invalid expiry option")
+}
+
+// remainingLifetime describes how long until a link expires, in the largest sensible unit
+func remainingLifetime(expiresAt time.Time) string {
+	remaining := time.Until(expiresAt)
+	switch {
+	case remaining <= 0:
+		return "Expired"
+	case remaining >= 48*time.Hour:
+		return fmt.Sprintf("%d days left", int(remaining.Hours()/24))
+	case remaining >= 2*time.Hour:
+		return fmt.Sprintf("%d hours left", int(remaining.Hours()))
+	default:
+		return "Less than 2 hours left"
+	}
+}
+
+// describeExpiry fills in the dashboard display fields of a mapping
+func describeExpiry(mapping *URLMapping) {
+	if mapping.ExpiresAt == nil {
+		mapping.ExpiresIn = "Never expires"
+		return
+	}
+
+	expiresAt, err := parseTimestamp(*mapping.ExpiresAt)
+	if err != nil {
+		mapping.ExpiresIn = *mapping.ExpiresAt
+		return
+	}
+
+	mapping.Expired = !expiresAt.After(time.Now())
+	mapping.ExpiresIn = remainingLifetime(expiresAt)
+}
+
+// HandleRenew extends a user's shortlink by its original lifetime
+func (s *Server) handleRenew(w http.ResponseWriter, r *http.Request) {
+	s.handleExpiryChange(w, r, AuditLinkRenew, func(r *http.Request, days *int) (LinkExpiry, error) {
+		if days != nil {
+			return expiryInDays(*days), nil
+		}
+		return expiryInDays(DefaultRenewalDays), nil
+	})
+}
+
+// HandleExpiry sets a new expiry chosen by the owner
+func (s *Server) handleExpiry(w http.ResponseWriter, r *http.Request) {
+	s.handleExpiryChange(w, r, AuditLinkExpiry, func(r *http.Request, days *int) (LinkExpiry, error) {
+		return parseLinkExpiry(r.FormValue("expiry"), r.FormValue("expiry_date"))
+	})
+}
+
+// handleExpiryChange applies an expiry computed by compute to a link the user owns, recording
+// it in the audit log as action
+func (s *Server) handleExpiryChange(w http.ResponseWriter, r *http.Request, action string, compute func(r *http.Request, days *int) (LinkExpiry, error)) {
+	// Check if user is authenticated
+	user, err := s.getCurrentUser(r)
+	if err != nil {
+		// Not authenticated, redirect to login
+		s.redirectToLogin(w, r)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	shortCode := strings.ToLower(strings.TrimSpace(r.FormValue("short_code")))
+	if shortCode == "" {
+		http.Error(w, "Short code is required", http.StatusBadRequest)
+		return
+	}
+
+	// Expired links are still owned, so look them up without the expiry filter
+	mapping, err := s.store.GetLinkRecord(r.Context(), shortCode)
+	if err == nil {
+		_, err = s.requireLinkRole(r.Context(), shortCode, user.ID, RoleEditor)
+	}
+
+	if err != nil {
+		s.renderLinkError(w, err, shortCode, "change the expiry of", "Failed to check link ownership")
+		return
+	}
+
+	expiry, err := compute(r, mapping.ExpiryDays)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.SetLinkExpiry(r.Context(), shortCode, user.ID, expiry); err != nil {
+		s.renderLinkError(w, err, shortCode, "change the expiry of", "Failed to update link expiry")
+		return
+	}
+	s.cache.Invalidate(shortCode)
+
+	event := newAuditEvent(user.ID, action, shortCode)
+	event.Details = describeExpiryChange(expiry)
+	onBehalfOf(event, mapping)
+	s.audit(r.Context(), event)
+
+	// Success - redirect back to dashboard (root)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// renderExpired displays the page shown when a short code has passed its expiry
+func (s *Server) renderExpired(w http.ResponseWriter, r *http.Request, mapping *URLMapping) {
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(http.StatusGone)
+
+	expiredAt := ""
+	if mapping.ExpiresAt != nil {
+		expiredAt = formatTimestamp(*mapping.ExpiresAt)
+	}
+
+	data := struct {
+		ShortCode  string
+		ExpiredAt  string
+		BaseDomain string
+	}{
+		ShortCode:  mapping.ShortCode,
+		ExpiredAt:  expiredAt,
+		BaseDomain: s.getBaseDomain(r.Host),
+	}
+
+	err := s.templates.ExecuteTemplate(w, "expired.html", data)
+	if err != nil {
+		log.Printf("Template error: %v", err)
+	}
+}
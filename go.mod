@@ -4,6 +4,7 @@ go 1.24.3
 
 require (
 	github.com/BurntSushi/toml v1.5.0
+	github.com/lib/pq v1.10.9
 	github.com/realTristan/disgoauth v1.0.2
 	modernc.org/sqlite v1.38.0
 )
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// ErrGuildNotManaged is returned when verified registration is required and the user can't
+// manage the invite's guild
+var ErrGuildNotManaged = errors.New("You need the Manage Server permission in this invite's Discord server to use it. If you were just given it, log out and back in.")
+
+// syncManagedGuilds records which guilds a user can manage, using the access token from their
+// login. A failure is logged rather than refusing the login; the previous list is kept.
+func (s *Server) syncManagedGuilds(ctx context.Context, userID, accessToken string) {
+	if !s.config.GuildVerificationEnabled() {
+		return
+	}
+
+	guildIDs, err := s.discord.ManagedGuilds(ctx, accessToken)
+	if err != nil {
+		log.Printf("Failed to fetch guilds for user %s: %v", userID, err)
+		return
+	}
+	if err := s.store.SetManagedGuilds(ctx, userID, guildIDs); err != nil {
+		log.Printf("Failed to save guilds for user %s: %v", userID, err)
+	}
+}
+
+// verifyInvite marks an invite verified if the user manages its guild. When unverified links
+// are refused it fails with ErrGuildNotManaged instead.
+func (s *Server) verifyInvite(ctx context.Context, userID string, invite *DiscordInvite) error {
+	if !s.config.GuildVerificationEnabled() {
+		return nil
+	}
+
+	managed, err := s.store.ManagesGuild(ctx, userID, invite.GuildID)
+	if err != nil {
+		return err
+	}
+	if !managed && s.config.Discord.RequireVerified {
+		return ErrGuildNotManaged
+	}
+	invite.Verified = managed
+	return nil
+}
+
+// verifyError returns the HTTP status and user-facing message for a verification error,
+// logging failures that weren't the user's fault
+func verifyError(err error) (int, string) {
+	if errors.Is(err, ErrGuildNotManaged) {
+		return http.StatusForbidden, ErrGuildNotManaged.Error()
+	}
+	log.Printf("Guild verification failed: %v", err)
+	return http.StatusInternalServerError, "Failed to check whether you manage the invite's server"
+}
@@ -1,11 +1,10 @@
 package main
 
 import (
-	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"regexp"
 	"strings"
 	"time"
 )
@@ -16,18 +15,28 @@ func (s *Server) handleRegisterPage(w http.ResponseWriter, r *http.Request) {
 	user, err := s.getCurrentUser(r)
 	if err != nil {
 		// Not authenticated, redirect to login
-		http.Redirect(w, r, "/auth/login", http.StatusFound)
+		s.redirectToLogin(w, r)
 		return
 	}
 
 	// Handle GET request - show registration form
 	if r.Method == http.MethodGet {
 		data := struct {
-			User       *User
-			BaseDomain string
+			User            *User
+			BaseDomain      string
+			CodePolicy      *ShortCodePolicy
+			ExpiryDays      []int
+			MinDate         string
+			RequireVerified bool
+			CSRFToken       string
 		}{
-			User:       user,
-			BaseDomain: s.getBaseDomain(r.Host),
+			User:            user,
+			BaseDomain:      s.getBaseDomain(r.Host),
+			CSRFToken:       s.csrfToken(r),
+			CodePolicy:      s.codePolicy,
+			RequireVerified: s.config.Discord.RequireVerified,
+			ExpiryDays:      ExpiryPresetDays,
+			MinDate:         time.Now().UTC().Add(24 * time.Hour).Format("2006-01-02"),
 		}
 
 		w.Header().Set("Content-Type", "text/html")
@@ -41,6 +50,9 @@ func (s *Server) handleRegisterPage(w http.ResponseWriter, r *http.Request) {
 
 	// Handle POST request - process registration
 	if r.Method == http.MethodPost {
+		if !s.allowRequest(w, r, LimitRegister, "user:"+user.ID) {
+			return
+		}
 		s.handleRegisterSubmit(w, r, user)
 		return
 	}
@@ -51,34 +63,42 @@ func (s *Server) handleRegisterPage(w http.ResponseWriter, r *http.Request) {
 // HandleRegisterSubmit processes the registration form submission
 func (s *Server) handleRegisterSubmit(w http.ResponseWriter, r *http.Request, user *User) {
 	shortCode := strings.ToLower(strings.TrimSpace(r.FormValue("short_code")))
-	discordURL := strings.TrimSpace(r.FormValue("discord_url"))
 
 	// Validate inputs
-	if shortCode == "" {
-		http.Error(w, "Short code is required", http.StatusBadRequest)
+	if err := s.codePolicy.Validate(shortCode); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if len(shortCode) > 5 {
-		http.Error(w, "Short code must be 5 characters or less", http.StatusBadRequest)
+	discordURL, err := canonicalDiscordURL(r.FormValue("discord_url"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Validate alphanumeric characters only
-	if matched, _ := regexp.MatchString("^[a-zA-Z0-9]+$", shortCode); !matched {
-		http.Error(w, "Short code can only contain letters and numbers", http.StatusBadRequest)
+	expiry, err := parseLinkExpiry(r.FormValue("expiry"), r.FormValue("expiry_date"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if !DiscordURLRegex.MatchString(discordURL) {
-		http.Error(w, "Invalid Discord URL. Must be https://discord.gg/...", http.StatusBadRequest)
+	// Make sure the invite actually works before anyone shares the short link
+	invite, err := s.resolveInvite(r.Context(), discordURL)
+	if err != nil {
+		status, message := inviteError(err)
+		http.Error(w, message, status)
+		return
+	}
+	if err := s.verifyInvite(r.Context(), user.ID, invite); err != nil {
+		status, message := verifyError(err)
+		http.Error(w, message, status)
 		return
 	}
 
 	// Create URL mapping
-	err := s.createURLMapping(shortCode, discordURL, user.ID)
+	err = s.store.CreateLink(r.Context(), shortCode, discordURL, user.ID, expiry, invite)
 	if err != nil {
-		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		if errors.Is(err, ErrCodeTaken) {
 			http.Error(w, "Short code already exists", http.StatusConflict)
 			return
 		}
@@ -86,17 +106,33 @@ func (s *Server) handleRegisterSubmit(w http.ResponseWriter, r *http.Request, us
 		log.Printf("Database error: %v", err)
 		return
 	}
+	s.cache.Invalidate(shortCode)
+
+	event := newAuditEvent(user.ID, AuditLinkCreate, shortCode)
+	event.NewURL = &discordURL
+	s.audit(r.Context(), event)
 
 	// Success response
 	w.Header().Set("Content-Type", "text/html")
+	expiresAt := ""
+	if expiry.ExpiresAt != nil {
+		expiresAt = expiry.ExpiresAt.Format("Jan 2, 2006 15:04")
+	}
+
 	data := struct {
 		ShortCode  string
 		DiscordURL string
+		GuildName  string
+		Verified   bool
 		BaseDomain string
+		ExpiresAt  string
 	}{
 		ShortCode:  shortCode,
 		DiscordURL: discordURL,
+		GuildName:  invite.GuildName,
+		Verified:   invite.Verified,
 		BaseDomain: s.getBaseDomain(r.Host),
+		ExpiresAt:  expiresAt,
 	}
 
 	err = s.templates.ExecuteTemplate(w, "success.html", data)
@@ -111,8 +147,26 @@ func (s *Server) handleRedirect(w http.ResponseWriter, r *http.Request, shortCod
 	// Convert to lowercase for lookup
 	shortCode = strings.ToLower(shortCode)
 
-	discordURL, err := s.getURLMappingByShortCode(shortCode)
-	if err == sql.ErrNoRows {
+	clientKey := "ip:" + s.clientIP(r)
+	if !s.allowRequest(w, r, LimitRedirect, clientKey) {
+		return
+	}
+
+	// Clients that keep hitting unknown codes are probably enumerating, so block them
+	// until their not-found budget recovers
+	if limiter, ok := s.limiters[LimitNotFound]; ok && !s.config.RateLimits.Disabled {
+		if exhausted, retryAfter := limiter.Exhausted(clientKey); exhausted {
+			s.renderRateLimited(w, r, retryAfter)
+			return
+		}
+	}
+
+	mapping, err := s.lookupRedirect(r.Context(), shortCode)
+	if errors.Is(err, ErrNotFound) {
+		if !s.allowRequest(w, r, LimitNotFound, clientKey) {
+			return
+		}
+
 		s.renderError(w, 404, "Short Link Not Found",
 			fmt.Sprintf("The short code '%s' was not found.", shortCode),
 			"Please check the link or register a new one.")
@@ -125,8 +179,22 @@ func (s *Server) handleRedirect(w http.ResponseWriter, r *http.Request, shortCod
 		return
 	}
 
+	// Links taken down by a moderator stay registered but no longer redirect
+	if mapping.DisabledAt != nil {
+		s.renderError(w, http.StatusGone, "Link Disabled",
+			fmt.Sprintf("The short link '%s' has been disabled by a moderator.", shortCode),
+			"It was taken down after being reported for abuse.")
+		return
+	}
+
+	// Distinguish codes that have lapsed from ones that never existed
+	if !isActive(mapping) {
+		s.renderExpired(w, r, mapping)
+		return
+	}
+
 	// Redirect to Discord
-	http.Redirect(w, r, discordURL, http.StatusFound)
+	http.Redirect(w, r, mapping.DiscordURL, http.StatusFound)
 }
 
 // HandleStatic serves static assets
@@ -155,32 +223,111 @@ func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
 	user, err := s.getCurrentUser(r)
 	if err != nil {
 		// Not authenticated, redirect to login
-		http.Redirect(w, r, "/auth/login", http.StatusFound)
+		s.redirectToLogin(w, r)
 		return
 	}
 
 	// Get user's registered URLs
-	links, err := s.getUserMappings(user.ID)
+	links, err := s.store.ListUserLinks(r.Context(), user.ID)
 	if err != nil {
 		s.renderError(w, 500, "Database Error", "Failed to retrieve your links", err.Error())
 		return
 	}
 
-	// Format creation times
+	// Get user's personal access tokens
+	tokens, err := s.store.ListAPITokens(r.Context(), user.ID)
+	if err != nil {
+		s.renderError(w, 500, "Database Error", "Failed to retrieve your API tokens", err.Error())
+		return
+	}
+
+	// Get ownership transfers offered to the user and by them
+	incoming, err := s.store.ListIncomingTransfers(r.Context(), user.ID)
+	if err != nil {
+		s.renderError(w, 500, "Database Error", "Failed to retrieve link transfers", err.Error())
+		return
+	}
+	outgoingList, err := s.store.ListOutgoingTransfers(r.Context(), user.ID)
+	if err != nil {
+		s.renderError(w, 500, "Database Error", "Failed to retrieve link transfers", err.Error())
+		return
+	}
+	outgoing := make(map[string]*LinkTransfer, len(outgoingList))
+	for i := range outgoingList {
+		outgoing[outgoingList[i].ShortCode] = &outgoingList[i]
+	}
+	for i := range incoming {
+		incoming[i].ExpiresAt = formatTimestamp(incoming[i].ExpiresAt)
+	}
+
+	// Get invitations to help manage other users' links
+	invites, err := s.store.ListMemberInvites(r.Context(), user.ID)
+	if err != nil {
+		s.renderError(w, 500, "Database Error", "Failed to retrieve link invitations", err.Error())
+		return
+	}
+
+	// Get the user's recent activity, including changes others made to their links
+	activity, err := s.store.ListAuditEvents(r.Context(), AuditQuery{UserID: user.ID, Limit: activityLimit})
+	if err != nil {
+		s.renderError(w, 500, "Database Error", "Failed to retrieve your activity", err.Error())
+		return
+	}
+	formatAuditEvents(activity)
+
+	// Format creation times, collecting links whose invites stopped working so the
+	// owner sees them as soon as they log in
+	var deadLinks []string
 	for i := range links {
-		if t, err := time.Parse("2006-01-02 15:04:05", links[i].CreatedAt); err == nil {
-			links[i].CreatedAt = t.Format("Jan 2, 2006 15:04")
+		links[i].CreatedAt = formatTimestamp(links[i].CreatedAt)
+		if links[i].UpdatedAt != nil {
+			updated := formatTimestamp(*links[i].UpdatedAt)
+			links[i].UpdatedAt = &updated
+		}
+		if links[i].InviteExpiresAt != nil {
+			inviteExpires := formatTimestamp(*links[i].InviteExpiresAt)
+			links[i].InviteExpiresAt = &inviteExpires
+		}
+		describeExpiry(&links[i])
+		describeHealth(&links[i])
+		if links[i].Dead {
+			deadLinks = append(deadLinks, links[i].ShortCode)
+		}
+	}
+	for i := range tokens {
+		tokens[i].CreatedAt = formatTimestamp(tokens[i].CreatedAt)
+		if tokens[i].LastUsedAt != nil {
+			lastUsed := formatTimestamp(*tokens[i].LastUsedAt)
+			tokens[i].LastUsedAt = &lastUsed
 		}
 	}
 
 	data := struct {
 		User       *User
+		IsAdmin    bool
+		IsMod      bool
 		Links      []URLMapping
+		DeadLinks  []string
+		Tokens     []APIToken
+		Incoming   []LinkTransfer
+		Outgoing   map[string]*LinkTransfer
+		Invites    []LinkMember
+		Activity   []AuditEvent
 		BaseDomain string
+		CSRFToken  string
 	}{
 		User:       user,
+		IsAdmin:    s.config.IsAdmin(user.ID),
+		IsMod:      s.config.IsModerator(user.ID),
 		Links:      links,
+		DeadLinks:  deadLinks,
+		Tokens:     tokens,
+		Incoming:   incoming,
+		Outgoing:   outgoing,
+		Invites:    invites,
+		Activity:   activity,
 		BaseDomain: s.getBaseDomain(r.Host),
+		CSRFToken:  s.csrfToken(r),
 	}
 
 	w.Header().Set("Content-Type", "text/html")
@@ -197,7 +344,7 @@ func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
 	user, err := s.getCurrentUser(r)
 	if err != nil {
 		// Not authenticated, redirect to login
-		http.Redirect(w, r, "/auth/login", http.StatusFound)
+		s.redirectToLogin(w, r)
 		return
 	}
 
@@ -212,50 +359,149 @@ func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// First check if the link exists and belongs to the user
-	existingOwnerID, err := s.getURLMappingOwner(shortCode)
-	if err == sql.ErrNoRows {
-		s.renderError(w, 404, "Link Not Found",
-			fmt.Sprintf("The short code '%s' was not found.", shortCode),
-			"The link may have already been deleted.")
+	// Delete the link, which the store refuses unless the user owns or co-owns it
+	previous := s.auditedLink(r.Context(), shortCode)
+	if err := s.store.DeleteLink(r.Context(), shortCode, user.ID); err != nil {
+		s.renderLinkError(w, err, shortCode, "delete", "Failed to delete link")
 		return
 	}
+	s.cache.Invalidate(shortCode)
+
+	event := newAuditEvent(user.ID, AuditLinkDelete, shortCode)
+	if previous != nil {
+		event.OldURL = &previous.DiscordURL
+	}
+	onBehalfOf(event, previous)
+	s.audit(r.Context(), event)
+
+	// Success - redirect back to dashboard (root)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
 
+// HandleEdit changes the Discord destination of a user's shortlink (GET and POST)
+func (s *Server) handleEdit(w http.ResponseWriter, r *http.Request) {
+	// Check if user is authenticated
+	user, err := s.getCurrentUser(r)
 	if err != nil {
-		s.renderError(w, 500, "Database Error", "Failed to check link ownership", err.Error())
+		// Not authenticated, redirect to login
+		s.redirectToLogin(w, r)
 		return
 	}
 
-	// Check if the user owns this link
-	if existingOwnerID != user.ID {
-		s.renderError(w, 403, "Access Denied",
-			"You can only delete links that you created.",
-			fmt.Sprintf("The link '%s' belongs to another user.", shortCode))
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	shortCode := strings.ToLower(strings.TrimSpace(r.FormValue("short_code")))
+	if shortCode == "" {
+		http.Error(w, "Short code is required", http.StatusBadRequest)
+		return
+	}
+
+	// First check if the link exists and the user may edit it
+	mapping, err := s.store.GetLink(r.Context(), shortCode)
+	if err == nil {
+		_, err = s.requireLinkRole(r.Context(), shortCode, user.ID, RoleEditor)
+	}
+	if err == nil && mapping.DisabledAt != nil {
+		err = ErrLinkDisabled
+	}
+
+	if err != nil {
+		s.renderLinkError(w, err, shortCode, "edit", "Failed to check link ownership")
+		return
+	}
+
+	// Handle GET request - show edit form
+	if r.Method == http.MethodGet {
+		describeExpiry(mapping)
+
+		data := struct {
+			User       *User
+			Link       *URLMapping
+			BaseDomain string
+			ExpiryDays []int
+			MinDate    string
+			CSRFToken  string
+		}{
+			User:       user,
+			Link:       mapping,
+			BaseDomain: s.getBaseDomain(r.Host),
+			CSRFToken:  s.csrfToken(r),
+			ExpiryDays: ExpiryPresetDays,
+			MinDate:    time.Now().UTC().Add(24 * time.Hour).Format("2006-01-02"),
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		err = s.templates.ExecuteTemplate(w, "edit.html", data)
+		if err != nil {
+			http.Error(w, "Template error", http.StatusInternalServerError)
+			log.Printf("Template error: %v", err)
+		}
 		return
 	}
 
-	// Delete the link
-	rowsAffected, err := s.deleteURLMapping(shortCode, user.ID)
+	// Handle POST request - apply the new destination
+	discordURL, err := canonicalDiscordURL(r.FormValue("discord_url"))
 	if err != nil {
-		s.renderError(w, 500, "Database Error", "Failed to delete link", err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if rowsAffected == 0 {
-		s.renderError(w, 404, "Delete Failed",
-			"No link was deleted. It may have already been removed.",
-			"Please check your dashboard for current links.")
+	invite, err := s.resolveInvite(r.Context(), discordURL)
+	if err != nil {
+		status, message := inviteError(err)
+		http.Error(w, message, status)
+		return
+	}
+	if err := s.verifyInvite(r.Context(), user.ID, invite); err != nil {
+		status, message := verifyError(err)
+		http.Error(w, message, status)
 		return
 	}
 
+	if err := s.store.UpdateLinkURL(r.Context(), shortCode, user.ID, discordURL, invite); err != nil {
+		s.renderLinkError(w, err, shortCode, "edit", "Failed to update link")
+		return
+	}
+	s.cache.Invalidate(shortCode)
+
+	event := newAuditEvent(user.ID, AuditLinkUpdate, shortCode)
+	event.OldURL = &mapping.DiscordURL
+	event.NewURL = &discordURL
+	onBehalfOf(event, mapping)
+	s.audit(r.Context(), event)
+
 	// Success - redirect back to dashboard (root)
 	http.Redirect(w, r, "/", http.StatusFound)
 }
 
+// RenderLinkError displays the error page matching a store error for a link the user
+// tried to change; action completes "You don't have permission to ... this link."
+func (s *Server) renderLinkError(w http.ResponseWriter, err error, shortCode, action, failure string) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		s.renderError(w, 404, "Link Not Found",
+			fmt.Sprintf("The short code '%s' was not found.", shortCode),
+			"The link may have been deleted.")
+	case errors.Is(err, ErrLinkDisabled):
+		s.renderError(w, 403, "Link Disabled",
+			fmt.Sprintf("You can't %s this link because a moderator disabled it.", action),
+			"It was taken down after being reported for abuse. Contact the site operators if you think this is a mistake.")
+	case errors.Is(err, ErrNotOwner):
+		s.renderError(w, 403, "Access Denied",
+			fmt.Sprintf("You don't have permission to %s this link.", action),
+			fmt.Sprintf("The link '%s' belongs to another user, who can invite you to help manage it.", shortCode))
+	default:
+		s.renderError(w, 500, "Database Error", failure, err.Error())
+	}
+}
+
 // RenderError displays an error page
 func (s *Server) renderError(w http.ResponseWriter, statusCode int, title, message, details string) {
-	w.WriteHeader(statusCode)
 	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(statusCode)
 
 	data := struct {
 		StatusCode int
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// Invite health states recorded by the health checker
+const (
+	LinkHealthy = "healthy"
+	LinkDead    = "dead"
+)
+
+// inviteCheckBatch caps how many invites a single health check run resolves
+const inviteCheckBatch = 200
+
+// inviteCheckPause spaces out invite lookups so the checker stays well inside Discord's rate limits
+const inviteCheckPause = time.Second
+
+// checkInviteHealthJob resolves the invites behind links that are due a check and records
+// whether they still work. Links that haven't been checked recently enough are picked up
+// oldest first, so a large backlog is worked through over several runs.
+func (s *Server) checkInviteHealthJob(ctx context.Context) error {
+	checkedBefore := time.Now().Add(-s.config.GetInviteRecheckAge())
+	links, err := s.store.ListLinksToCheck(ctx, checkedBefore, inviteCheckBatch)
+	if err != nil {
+		return err
+	}
+
+	newlyDead := 0
+	for i, link := range links {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(inviteCheckPause):
+			}
+		}
+
+		health := LinkHealthy
+		_, err := s.resolveInvite(ctx, link.DiscordURL)
+		if errors.Is(err, ErrInviteInvalid) {
+			health = LinkDead
+		} else if err != nil {
+			// Don't mark anything dead because Discord is unreachable or rate limiting us;
+			// the remaining links are picked up again on the next run
+			return err
+		}
+
+		if err := s.store.SetLinkHealth(ctx, link.ShortCode, health, time.Now()); err != nil {
+			return err
+		}
+		if health == LinkDead && (link.Health == nil || *link.Health != LinkDead) {
+			newlyDead++
+			log.Printf("Invite behind %s is dead: %s", link.ShortCode, link.DiscordURL)
+		}
+	}
+
+	if newlyDead > 0 {
+		log.Printf("Checked %d invites, %d newly dead", len(links), newlyDead)
+	}
+	return nil
+}
+
+// describeHealth fills in the dashboard health fields of a mapping
+func describeHealth(mapping *URLMapping) {
+	mapping.Dead = mapping.Health != nil && *mapping.Health == LinkDead
+	if mapping.HealthCheckedAt != nil {
+		checked := formatTimestamp(*mapping.HealthCheckedAt)
+		mapping.HealthCheckedAt = &checked
+	}
+}
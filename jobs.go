@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+// registerJobs adds the server's background maintenance jobs to a scheduler
+func (s *Server) registerJobs(sched *Scheduler) {
+	sched.Register("purge-expired-sessions", s.config.GetSessionCleanupInterval(), s.purgeExpiredSessionsJob)
+	sched.Register("purge-expired-links", s.config.GetLinkCleanupInterval(), s.purgeExpiredLinksJob)
+	sched.Register("check-invite-health", s.config.GetInviteCheckInterval(), s.checkInviteHealthJob)
+	sched.Register("prune-rate-limiters", 10*time.Minute, s.pruneRateLimitersJob)
+}
+
+// purgeExpiredSessionsJob removes sessions past their expiry or idle timeout
+func (s *Server) purgeExpiredSessionsJob(ctx context.Context) error {
+	removed, err := s.store.PurgeExpiredSessions(ctx, s.sessionIdleSince())
+	if err != nil {
+		return err
+	}
+	if removed > 0 {
+		log.Printf("Purged %d expired sessions", removed)
+	}
+	return nil
+}
+
+// purgeExpiredLinksJob removes links that have been expired for longer than the grace period,
+// freeing their short codes for others to register
+func (s *Server) purgeExpiredLinksJob(ctx context.Context) error {
+	cutoff := time.Now().Add(-s.config.GetExpiredLinkGracePeriod())
+	removed, err := s.store.PurgeExpiredLinks(ctx, cutoff)
+	if err != nil {
+		return err
+	}
+	if removed > 0 {
+		// Purged codes are free to register again, so stop serving them from the cache
+		s.cache.Clear()
+		log.Printf("Purged %d expired links", removed)
+	}
+	return nil
+}
+
+// HandleJobStatus reports background job status as JSON to operators on the local machine
+func (s *Server) handleJobStatus(w http.ResponseWriter, r *http.Request) {
+	if !isLoopbackRequest(r) {
+		http.NotFound(w, r)
+		return
+	}
+
+	var jobs []JobStatus
+	if s.scheduler != nil {
+		jobs = s.scheduler.Status()
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{"jobs": jobs})
+}
+
+// isLoopbackRequest reports whether a request originated from the local machine.
+// Requests relayed by a reverse proxy are rejected since they only appear local.
+func isLoopbackRequest(r *http.Request) bool {
+	if r.Header.Get("X-Forwarded-For") != "" || r.Header.Get("X-Real-IP") != "" {
+		return false
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
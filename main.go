@@ -1,9 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
@@ -13,16 +19,97 @@ func main() {
 		log.Fatal("Failed to load config:", err)
 	}
 
+	// Handle subcommands that run and exit
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1:], config); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Open storage
+	store, err := OpenStore(config)
+	if err != nil {
+		log.Fatal("Failed to open storage:", err)
+	}
+	defer store.Close()
+
 	// Create server instance
-	server, err := InitServer("drop-reg.db", config)
+	server, err := InitServer(store, config)
 	if err != nil {
 		log.Fatal("Failed to create server:", err)
 	}
-	defer server.db.Close()
+
+	// Start background jobs
+	scheduler := NewScheduler()
+	server.registerJobs(scheduler)
+	server.scheduler = scheduler
+	scheduler.Start()
 
 	// Get port from configuration
 	port := config.GetPort()
 
-	log.Printf("Starting drop-reg.cc server on :%d", port)
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), server))
+	httpServer := &http.Server{
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: server,
+	}
+
+	// Stop cleanly on Ctrl+C or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		log.Printf("Starting drop-reg.cc server on :%d", port)
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Printf("Shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP shutdown error: %v", err)
+	}
+	scheduler.Stop()
+}
+
+// runCommand runs a command-line subcommand instead of the web server
+func runCommand(args []string, config *Config) error {
+	switch args[0] {
+	case "migrate":
+		store, err := OpenStore(config)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		migrator, ok := store.(Migrator)
+		if !ok {
+			return fmt.Errorf("storage backend %q does not use migrations", config.Server.Storage)
+		}
+
+		if len(args) > 1 && args[1] == "status" {
+			pending, err := migrator.PendingMigrations()
+			if err != nil {
+				return err
+			}
+			for _, migration := range pending {
+				fmt.Printf("pending %04d_%s\n", migration.Version, migration.Name)
+			}
+			fmt.Printf("%d pending migrations\n", len(pending))
+			return nil
+		}
+
+		applied, err := migrator.Migrate()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migrations\n", applied)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q (available: migrate, migrate status)", args[0])
+	}
 }
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Link member roles. Editors can change a link's destination and expiry; owners can also
+// delete it and manage its members. Only the link's primary owner can transfer it.
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+)
+
+// MemberRole pairs a member role with its human readable description
+type MemberRole struct {
+	Role  string
+	Label string
+}
+
+// Roles an owner can invite someone with, in the order the invite form offers them
+var memberRoles = []MemberRole{
+	{RoleEditor, "Editor - can change the destination and expiry"},
+	{RoleOwner, "Co-owner - can also delete the link and manage members"},
+}
+
+// validMemberRole reports whether a role can be granted by invitation
+func validMemberRole(role string) bool {
+	for _, r := range memberRoles {
+		if r.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveRole combines a link's owner with a user's accepted membership, returning "" if
+// the user has no role on the link
+func resolveRole(ownerID, memberRole *string, userID string) string {
+	if ownerID != nil && *ownerID == userID {
+		return RoleOwner
+	}
+	if memberRole == nil {
+		return ""
+	}
+	return *memberRole
+}
+
+// hasRole reports whether role grants at least the access of required
+func hasRole(role, required string) bool {
+	switch required {
+	case RoleOwner:
+		return role == RoleOwner
+	case RoleEditor:
+		return role == RoleOwner || role == RoleEditor
+	}
+	return false
+}
+
+// OwnedBy reports whether userID is the link's primary owner
+func (m URLMapping) OwnedBy(userID string) bool {
+	return m.OwnerID != nil && *m.OwnerID == userID
+}
+
+// onBehalfOf records a link's owner as the subject of an event a co-manager caused, so the
+// change shows up in the owner's activity
+func onBehalfOf(event *AuditEvent, mapping *URLMapping) {
+	if mapping == nil || mapping.OwnerID == nil {
+		return
+	}
+	if event.ActorID == nil || *event.ActorID != *mapping.OwnerID {
+		event.SubjectID = mapping.OwnerID
+	}
+}
+
+// requireLinkRole returns the user's role on a link, or ErrNotOwner unless it grants at
+// least the required access
+func (s *Server) requireLinkRole(ctx context.Context, shortCode, userID, required string) (string, error) {
+	role, err := s.store.LinkRole(ctx, shortCode, userID)
+	if err != nil {
+		return "", err
+	}
+	if !hasRole(role, required) {
+		return "", ErrNotOwner
+	}
+	return role, nil
+}
+
+// HandleMembers routes link member management requests
+func (s *Server) handleMembers(w http.ResponseWriter, r *http.Request, membersPath string) {
+	// Check if user is authenticated
+	user, err := s.getCurrentUser(r)
+	if err != nil {
+		// Not authenticated, redirect to login
+		s.redirectToLogin(w, r)
+		return
+	}
+
+	shortCode := strings.ToLower(strings.TrimSpace(r.FormValue("short_code")))
+	if shortCode == "" {
+		http.Error(w, "Short code is required", http.StatusBadRequest)
+		return
+	}
+
+	if membersPath == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleMembersPage(w, r, user, shortCode)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch membersPath {
+	case "invite":
+		s.handleMemberInvite(w, r, user, shortCode)
+	case "remove":
+		s.handleMemberRemove(w, r, user, shortCode)
+	case "accept":
+		s.handleMemberAccept(w, r, user, shortCode)
+	case "decline":
+		s.handleMemberDecline(w, r, user, shortCode)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// HandleMembersPage lists who can manage a link, with invite and remove forms for owners
+func (s *Server) handleMembersPage(w http.ResponseWriter, r *http.Request, user *User, shortCode string) {
+	// Expired links can still be renewed, so their members stay visible
+	mapping, err := s.store.GetLinkRecord(r.Context(), shortCode)
+	if err == nil {
+		mapping.Role, err = s.requireLinkRole(r.Context(), shortCode, user.ID, RoleEditor)
+	}
+	if err != nil {
+		s.renderLinkError(w, err, shortCode, "view the members of", "Failed to check link access")
+		return
+	}
+
+	members, err := s.store.ListLinkMembers(r.Context(), shortCode)
+	if err != nil {
+		s.renderError(w, 500, "Database Error", "Failed to retrieve link members", err.Error())
+		return
+	}
+	for i := range members {
+		members[i].CreatedAt = formatTimestamp(members[i].CreatedAt)
+	}
+
+	data := struct {
+		User       *User
+		Link       *URLMapping
+		Members    []LinkMember
+		Roles      []MemberRole
+		BaseDomain string
+		CSRFToken  string
+	}{
+		User:       user,
+		Link:       mapping,
+		Members:    members,
+		Roles:      memberRoles,
+		BaseDomain: s.getBaseDomain(r.Host),
+		CSRFToken:  s.csrfToken(r),
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	err = s.templates.ExecuteTemplate(w, "members.html", data)
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		log.Printf("Template error: %v", err)
+	}
+}
+
+// HandleMemberInvite invites a Discord user to help manage a link, or changes a member's role
+func (s *Server) handleMemberInvite(w http.ResponseWriter, r *http.Request, user *User, shortCode string) {
+	memberID := strings.TrimSpace(r.FormValue("user_id"))
+	if !discordUserIDRegex.MatchString(memberID) {
+		http.Error(w, "Enter the member's numeric Discord user ID", http.StatusBadRequest)
+		return
+	}
+
+	role := r.FormValue("role")
+	if !validMemberRole(role) {
+		http.Error(w, "Choose a valid role", http.StatusBadRequest)
+		return
+	}
+
+	mapping, err := s.store.GetLinkRecord(r.Context(), shortCode)
+	if err != nil {
+		s.renderLinkError(w, err, shortCode, "manage the members of", "Failed to check link access")
+		return
+	}
+	if mapping.OwnedBy(memberID) {
+		http.Error(w, "That user already owns this link", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.InviteMember(r.Context(), shortCode, user.ID, memberID, role); err != nil {
+		s.renderLinkError(w, err, shortCode, "manage the members of", "Failed to invite member")
+		return
+	}
+	log.Printf("User %s invited user %s to link %s as %s", user.ID, memberID, shortCode, role)
+
+	event := newAuditEvent(user.ID, AuditMemberInvite, shortCode)
+	event.SubjectID = &memberID
+	event.Details = "as " + role
+	s.audit(r.Context(), event)
+
+	http.Redirect(w, r, membersURL(shortCode), http.StatusFound)
+}
+
+// HandleMemberRemove removes a member or invitation; members may also remove themselves
+func (s *Server) handleMemberRemove(w http.ResponseWriter, r *http.Request, user *User, shortCode string) {
+	memberID := strings.TrimSpace(r.FormValue("user_id"))
+	if memberID == "" {
+		http.Error(w, "Member is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.RemoveMember(r.Context(), shortCode, user.ID, memberID); err != nil {
+		s.renderMemberError(w, err, shortCode, "Failed to remove member")
+		return
+	}
+
+	event := newAuditEvent(user.ID, AuditMemberRemove, shortCode)
+	event.SubjectID = &memberID
+	s.audit(r.Context(), event)
+
+	// Members who left can no longer see the page
+	if memberID == user.ID {
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+	http.Redirect(w, r, membersURL(shortCode), http.StatusFound)
+}
+
+// HandleMemberAccept starts helping to manage a link the user was invited to
+func (s *Server) handleMemberAccept(w http.ResponseWriter, r *http.Request, user *User, shortCode string) {
+	if err := s.store.AcceptMemberInvite(r.Context(), shortCode, user.ID); err != nil {
+		s.renderMemberError(w, err, shortCode, "Failed to accept invitation")
+		return
+	}
+
+	event := newAuditEvent(user.ID, AuditMemberAccept, shortCode)
+	onBehalfOf(event, s.auditedLink(r.Context(), shortCode))
+	s.audit(r.Context(), event)
+
+	// Success - redirect back to dashboard (root)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// HandleMemberDecline refuses an invitation to manage a link
+func (s *Server) handleMemberDecline(w http.ResponseWriter, r *http.Request, user *User, shortCode string) {
+	if err := s.store.RemoveMember(r.Context(), shortCode, user.ID, user.ID); err != nil {
+		s.renderMemberError(w, err, shortCode, "Failed to decline invitation")
+		return
+	}
+
+	event := newAuditEvent(user.ID, AuditMemberDecline, shortCode)
+	onBehalfOf(event, s.auditedLink(r.Context(), shortCode))
+	s.audit(r.Context(), event)
+
+	// Success - redirect back to dashboard (root)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// membersURL returns the member management page of a link
+func membersURL(shortCode string) string {
+	return "/members?short_code=" + url.QueryEscape(shortCode)
+}
+
+// renderMemberError displays the error page matching a store error for a membership change
+func (s *Server) renderMemberError(w http.ResponseWriter, err error, shortCode, failure string) {
+	if errors.Is(err, ErrNotFound) {
+		s.renderError(w, 404, "Member Not Found",
+			"That user isn't a member of this link.",
+			"The invitation may have been withdrawn, or the link may have been deleted.")
+		return
+	}
+	s.renderLinkError(w, err, shortCode, "manage the members of", failure)
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var migrationFiles embed.FS
+
+// Migration is a numbered schema change applied once, in order
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// loadMigrations reads the numbered .sql files in dir, named like 0001_description.sql
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	var migrations []Migration
+	seen := make(map[int]string)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+
+		base := strings.TrimSuffix(entry.Name(), ".sql")
+		number, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(number)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+		if other, dup := seen[version]; dup {
+			return nil, fmt.Errorf("migrations %q and %q share version %d", other, entry.Name(), version)
+		}
+		seen[version] = entry.Name()
+
+		contents, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %q: %w", entry.Name(), err)
+		}
+
+		migrations = append(migrations, Migration{Version: version, Name: name, SQL: string(contents)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// migrations returns the embedded migrations for the database in use
+func (st *SQLStore) migrations() ([]Migration, error) {
+	return loadMigrations(migrationFiles, st.dialect.migrationsDir)
+}
+
+// ensureMigrationsTable creates the table recording applied migrations
+func (st *SQLStore) ensureMigrationsTable() error {
+	_, err := st.db.Exec(st.dialect.migrationsTable)
+	return err
+}
+
+// appliedMigrations returns the set of migration versions already applied
+func (st *SQLStore) appliedMigrations() (map[int]bool, error) {
+	rows, err := st.db.Query("SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// adoptLegacySchema records the migrations a pre-migration database already satisfies,
+// so they are not re-applied over existing tables
+func (st *SQLStore) adoptLegacySchema(migrations []Migration, applied map[int]bool) error {
+	legacyMarkers := st.dialect.legacyMarkers
+	if len(applied) > 0 || legacyMarkers == nil {
+		return nil
+	}
+
+	// Only a database that already has the initial schema predates schema_migrations
+	legacy, err := legacyMarkers[1](st)
+	if err != nil || !legacy {
+		return err
+	}
+
+	for _, migration := range migrations {
+		marker, ok := legacyMarkers[migration.Version]
+		if !ok {
+			break
+		}
+		present, err := marker(st)
+		if err != nil {
+			return err
+		}
+		if !present {
+			break
+		}
+
+		if _, err := st.db.Exec(
+			st.rebind("INSERT INTO schema_migrations (version, name) VALUES (?, ?)"),
+			migration.Version, migration.Name,
+		); err != nil {
+			return err
+		}
+		applied[migration.Version] = true
+		log.Printf("Adopted existing schema as migration %04d_%s", migration.Version, migration.Name)
+	}
+	return nil
+}
+
+// PendingMigrations checks the database against the embedded migrations, returning those
+// not yet applied. It fails if the database has migrations this binary does not know about.
+func (st *SQLStore) PendingMigrations() ([]Migration, error) {
+	migrations, err := st.migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := st.ensureMigrationsTable(); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	applied, err := st.appliedMigrations()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+
+	if err := st.adoptLegacySchema(migrations, applied); err != nil {
+		return nil, fmt.Errorf("failed to adopt existing schema: %w", err)
+	}
+
+	known := make(map[int]bool, len(migrations))
+	latest := 0
+	for _, migration := range migrations {
+		known[migration.Version] = true
+		latest = migration.Version
+	}
+
+	for version := range applied {
+		if !known[version] {
+			return nil, fmt.Errorf("database has migration %d but this binary only knows up to %d; refusing to start with an older binary", version, latest)
+		}
+	}
+
+	var pending []Migration
+	for _, migration := range migrations {
+		if !applied[migration.Version] {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// applyMigration runs a single migration and records it, all in one transaction
+func (st *SQLStore) applyMigration(migration Migration) error {
+	tx, err := st.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(migration.SQL); err != nil {
+		return fmt.Errorf("migration %04d_%s failed: %w", migration.Version, migration.Name, err)
+	}
+
+	if _, err := tx.Exec(
+		st.rebind("INSERT INTO schema_migrations (version, name) VALUES (?, ?)"),
+		migration.Version, migration.Name,
+	); err != nil {
+		return fmt.Errorf("failed to record migration %04d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	return tx.Commit()
+}
+
+// Migrate applies every pending migration in order, returning how many were applied
+func (st *SQLStore) Migrate() (int, error) {
+	pending, err := st.PendingMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	for i, migration := range pending {
+		if err := st.applyMigration(migration); err != nil {
+			return i, err
+		}
+		log.Printf("Applied migration %04d_%s", migration.Version, migration.Name)
+	}
+	return len(pending), nil
+}
+
+// initDB brings the database schema up to date at startup, or only verifies it when
+// migrations are configured to be run manually
+func (s *Server) initDB() error {
+	migrator, ok := s.store.(Migrator)
+	if !ok {
+		return nil
+	}
+
+	if !s.config.Server.ManualMigrations {
+		_, err := migrator.Migrate()
+		return err
+	}
+
+	pending, err := migrator.PendingMigrations()
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%d pending migrations; run \"drop-reg migrate\" first", len(pending))
+	}
+	return nil
+}
+
+// sqliteTableExists reports whether a table exists in a SQLite database
+func (st *SQLStore) sqliteTableExists(table string) (bool, error) {
+	var name string
+	err := st.db.QueryRow(
+		"SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?",
+		table,
+	).Scan(&name)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// sqliteColumnExists reports whether a table in a SQLite database has a column
+func (st *SQLStore) sqliteColumnExists(table, column string) (bool, error) {
+	var count int
+	err := st.db.QueryRow(
+		"SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?",
+		table, column,
+	).Scan(&count)
+	return count > 0, err
+}
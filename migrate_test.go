@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openMigrationTestStore opens an empty SQLite database in a temporary file, without migrating it
+func openMigrationTestStore(t *testing.T) *SQLStore {
+	t.Helper()
+	store, err := OpenSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLiteStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// migrateThrough applies the pending migrations up to and including version
+func migrateThrough(t *testing.T, store *SQLStore, version int) {
+	t.Helper()
+	pending, err := store.PendingMigrations()
+	if err != nil {
+		t.Fatalf("PendingMigrations: %v", err)
+	}
+	for _, migration := range pending {
+		if migration.Version > version {
+			break
+		}
+		if err := store.applyMigration(migration); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// appliedVersions returns the versions recorded in schema_migrations, in order
+func appliedVersions(t *testing.T, store *SQLStore) []int {
+	t.Helper()
+	rows, err := store.db.Query("SELECT version FROM schema_migrations ORDER BY version")
+	if err != nil {
+		t.Fatalf("reading schema_migrations: %v", err)
+	}
+	defer rows.Close()
+
+	var versions []int
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			t.Fatal(err)
+		}
+		versions = append(versions, version)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return versions
+}
+
+func TestMigrateRefusesNewerDatabase(t *testing.T) {
+	store := openMigrationTestStore(t)
+	if _, err := store.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	// A newer binary has since applied a migration this one doesn't have
+	if _, err := store.db.Exec("INSERT INTO schema_migrations (version, name) VALUES (9999, 'from_the_future')"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.PendingMigrations(); err == nil || !strings.Contains(err.Error(), "9999") {
+		t.Errorf("PendingMigrations = %v, want an error naming migration 9999", err)
+	}
+	if applied, err := store.Migrate(); err == nil || applied != 0 {
+		t.Errorf("Migrate = %d, %v; want it to refuse", applied, err)
+	}
+}
+
+func TestMigrateAdoptsLegacySchema(t *testing.T) {
+	tests := []struct {
+		name string
+		// legacy is the last migration whose schema the database was created with ad hoc, before
+		// schema_migrations existed
+		legacy       int
+		wantAdopted  []int
+		wantFirstRun int
+	}{
+		{name: "new database", legacy: 0, wantAdopted: nil, wantFirstRun: 1},
+		{name: "initial schema", legacy: 1, wantAdopted: []int{1}, wantFirstRun: 2},
+		{name: "schema up to expiry days", legacy: 4, wantAdopted: []int{1, 2, 3, 4}, wantFirstRun: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := openMigrationTestStore(t)
+
+			migrations, err := store.migrations()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, migration := range migrations[:tt.legacy] {
+				if _, err := store.db.Exec(migration.SQL); err != nil {
+					t.Fatalf("creating legacy schema %04d: %v", migration.Version, err)
+				}
+			}
+			if tt.legacy > 0 {
+				if _, err := store.db.Exec("INSERT INTO url_mappings (short_code, discord_url, owner_id) VALUES ('abc', 'https://discord.gg/abc', 'u1')"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			pending, err := store.PendingMigrations()
+			if err != nil {
+				t.Fatalf("PendingMigrations: %v", err)
+			}
+			if len(pending) == 0 || pending[0].Version != tt.wantFirstRun {
+				t.Fatalf("%d pending migrations, want the first to be %04d", len(pending), tt.wantFirstRun)
+			}
+			if got := appliedVersions(t, store); !slices.Equal(got, tt.wantAdopted) {
+				t.Errorf("adopted %v, want %v", got, tt.wantAdopted)
+			}
+
+			// The rest apply on top of the adopted schema without touching existing rows
+			if _, err := store.Migrate(); err != nil {
+				t.Fatalf("Migrate: %v", err)
+			}
+			if got := appliedVersions(t, store); len(got) != len(migrations) {
+				t.Errorf("applied %v, want all %d migrations", got, len(migrations))
+			}
+			if tt.legacy > 0 {
+				var count int
+				if err := store.db.QueryRow("SELECT COUNT(*) FROM url_mappings WHERE short_code = 'abc'").Scan(&count); err != nil || count != 1 {
+					t.Errorf("legacy link count = %d, %v; want 1", count, err)
+				}
+			}
+		})
+	}
+}
+
+func TestMigrateHashesSessionTokens(t *testing.T) {
+	store := openMigrationTestStore(t)
+	migrateThrough(t, store, 13)
+
+	// Before 0014 the session table held the cookie's token itself
+	const token = "plaintext-session-token"
+	now := time.Now()
+	if _, err := store.db.Exec("INSERT INTO users (id, username, avatar, discriminator) VALUES ('u1', 'u1', '', '0')"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.db.Exec(
+		"INSERT INTO sessions (id, user_id, created_at, last_seen_at, expires_at) VALUES (?, 'u1', ?, ?, ?)",
+		token, dbTime(now), dbTime(now), dbTime(now.Add(time.Hour)),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	var stored string
+	if err := store.db.QueryRow("SELECT id FROM sessions WHERE user_id = 'u1'").Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored != hashSessionToken(token) {
+		t.Errorf("stored session id = %q, want the token's digest %q", stored, hashSessionToken(token))
+	}
+
+	// The cookie issued before the upgrade still logs in, and the digest alone doesn't
+	ctx := context.Background()
+	idleSince := now.Add(-time.Hour)
+	if user, err := store.GetSessionUser(ctx, hashSessionToken(token), idleSince); err != nil || user.ID != "u1" {
+		t.Errorf("GetSessionUser(old cookie) = %v, %v; want u1", user, err)
+	}
+	if _, err := store.GetSessionUser(ctx, hashSessionToken(stored), idleSince); err == nil {
+		t.Error("GetSessionUser accepted the stored digest as a cookie")
+	}
+}
@@ -0,0 +1,26 @@
+-- Initial schema, matching the SQLite baseline. Timestamps are UTC without a time zone.
+CREATE TABLE url_mappings (
+	id SERIAL PRIMARY KEY,
+	short_code TEXT UNIQUE NOT NULL,
+	discord_url TEXT NOT NULL,
+	created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'utc'),
+	expires_at TIMESTAMP,
+	owner_id TEXT NOT NULL
+);
+
+CREATE TABLE users (
+	id TEXT PRIMARY KEY,
+	username TEXT NOT NULL,
+	avatar TEXT,
+	discriminator TEXT,
+	created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'utc')
+);
+
+CREATE TABLE sessions (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL REFERENCES users (id),
+	created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'utc'),
+	expires_at TIMESTAMP NOT NULL
+);
+CREATE INDEX idx_sessions_user_id ON sessions(user_id);
+CREATE INDEX idx_sessions_expires ON sessions(expires_at);
@@ -0,0 +1,10 @@
+-- Personal access tokens for the JSON API
+CREATE TABLE api_tokens (
+	id SERIAL PRIMARY KEY,
+	user_id TEXT NOT NULL REFERENCES users (id),
+	name TEXT NOT NULL,
+	token_hash TEXT UNIQUE NOT NULL,
+	created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'utc'),
+	last_used_at TIMESTAMP
+);
+CREATE INDEX idx_api_tokens_user_id ON api_tokens(user_id);
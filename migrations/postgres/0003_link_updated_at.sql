@@ -0,0 +1,2 @@
+-- When a link's Discord destination last changed
+ALTER TABLE url_mappings ADD COLUMN updated_at TIMESTAMP;
@@ -0,0 +1,3 @@
+-- Relative lifetime chosen by the owner, reused when the link is renewed
+ALTER TABLE url_mappings ADD COLUMN expiry_days INTEGER;
+CREATE INDEX idx_url_mappings_expires ON url_mappings(expires_at);
@@ -0,0 +1,4 @@
+-- Guild an invite resolved to when it was registered, and when Discord will expire the invite
+ALTER TABLE url_mappings ADD COLUMN guild_id TEXT;
+ALTER TABLE url_mappings ADD COLUMN guild_name TEXT;
+ALTER TABLE url_mappings ADD COLUMN invite_expires_at TIMESTAMP;
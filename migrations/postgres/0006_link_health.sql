@@ -0,0 +1,4 @@
+-- Result of the periodic invite health check: NULL until checked, then 'healthy' or 'dead'
+ALTER TABLE url_mappings ADD COLUMN health TEXT;
+ALTER TABLE url_mappings ADD COLUMN health_checked_at TIMESTAMP;
+CREATE INDEX idx_url_mappings_health_checked ON url_mappings(health_checked_at);
@@ -0,0 +1,2 @@
+-- Set when an admin disables an account, which stops it signing in or using API tokens
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMP;
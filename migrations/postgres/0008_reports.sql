@@ -0,0 +1,20 @@
+-- Links disabled by moderators stop redirecting until re-enabled
+ALTER TABLE url_mappings ADD COLUMN disabled_at TIMESTAMP;
+
+-- Abuse reports filed against short codes. The code, URL and owner are copied so reports
+-- outlive the link they describe.
+CREATE TABLE reports (
+	id SERIAL PRIMARY KEY,
+	short_code TEXT NOT NULL,
+	discord_url TEXT NOT NULL,
+	owner_id TEXT,
+	reason TEXT NOT NULL,
+	details TEXT NOT NULL DEFAULT '',
+	reporter_id TEXT,
+	status TEXT NOT NULL DEFAULT 'open',
+	created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'utc'),
+	resolved_at TIMESTAMP,
+	resolved_by TEXT
+);
+CREATE INDEX idx_reports_status ON reports(status, created_at);
+CREATE INDEX idx_reports_short_code ON reports(short_code);
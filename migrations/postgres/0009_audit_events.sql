@@ -0,0 +1,26 @@
+-- Append-only record of every link and account mutation. Short codes and user IDs are
+-- copied rather than referenced so events outlive what they describe.
+CREATE TABLE audit_events (
+	id SERIAL PRIMARY KEY,
+	actor_id TEXT,
+	action TEXT NOT NULL,
+	short_code TEXT,
+	subject_id TEXT,
+	old_url TEXT,
+	new_url TEXT,
+	details TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'utc')
+);
+CREATE INDEX idx_audit_events_created_at ON audit_events(created_at);
+CREATE INDEX idx_audit_events_actor_id ON audit_events(actor_id);
+CREATE INDEX idx_audit_events_subject_id ON audit_events(subject_id);
+CREATE INDEX idx_audit_events_short_code ON audit_events(short_code);
+
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_append_only BEFORE UPDATE OR DELETE ON audit_events
+	FOR EACH ROW EXECUTE PROCEDURE audit_events_append_only();
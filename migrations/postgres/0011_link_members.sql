@@ -0,0 +1,13 @@
+-- Users who co-manage a link alongside its owner. Members are keyed by link ID rather than
+-- short code so they never carry over to a later registration of the same code.
+-- accepted_at is NULL while the invitation is pending.
+CREATE TABLE link_members (
+	link_id INTEGER NOT NULL REFERENCES url_mappings (id) ON DELETE CASCADE,
+	user_id TEXT NOT NULL,
+	role TEXT NOT NULL,
+	invited_by TEXT NOT NULL,
+	created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'utc'),
+	accepted_at TIMESTAMP,
+	PRIMARY KEY (link_id, user_id)
+);
+CREATE INDEX idx_link_members_user_id ON link_members(user_id);
@@ -0,0 +1,2 @@
+-- Discord display name, shown in place of the unique username when set. Refreshed at each login
+ALTER TABLE users ADD COLUMN global_name TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,28 @@
+-- Schema as shipped before versioned migrations were introduced
+CREATE TABLE IF NOT EXISTS url_mappings (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	short_code TEXT UNIQUE NOT NULL,
+	discord_url TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	expires_at DATETIME,
+	owner_id TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_short_code ON url_mappings(short_code);
+
+CREATE TABLE IF NOT EXISTS users (
+	id TEXT PRIMARY KEY,
+	username TEXT NOT NULL,
+	avatar TEXT,
+	discriminator TEXT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	expires_at DATETIME NOT NULL,
+	FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_expires ON sessions(expires_at);
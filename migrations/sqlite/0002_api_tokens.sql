@@ -0,0 +1,11 @@
+-- Personal access tokens for the JSON API
+CREATE TABLE api_tokens (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id TEXT NOT NULL,
+	name TEXT NOT NULL,
+	token_hash TEXT UNIQUE NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	last_used_at DATETIME,
+	FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE INDEX idx_api_tokens_user_id ON api_tokens(user_id);
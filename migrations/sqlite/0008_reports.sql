@@ -0,0 +1,20 @@
+-- Links disabled by moderators stop redirecting until re-enabled
+ALTER TABLE url_mappings ADD COLUMN disabled_at DATETIME;
+
+-- Abuse reports filed against short codes. The code, URL and owner are copied so reports
+-- outlive the link they describe.
+CREATE TABLE reports (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	short_code TEXT NOT NULL,
+	discord_url TEXT NOT NULL,
+	owner_id TEXT,
+	reason TEXT NOT NULL,
+	details TEXT NOT NULL DEFAULT '',
+	reporter_id TEXT,
+	status TEXT NOT NULL DEFAULT 'open',
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	resolved_at DATETIME,
+	resolved_by TEXT
+);
+CREATE INDEX idx_reports_status ON reports(status, created_at);
+CREATE INDEX idx_reports_short_code ON reports(short_code);
@@ -0,0 +1,27 @@
+-- Append-only record of every link and account mutation. Short codes and user IDs are
+-- copied rather than referenced so events outlive what they describe.
+CREATE TABLE audit_events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	actor_id TEXT,
+	action TEXT NOT NULL,
+	short_code TEXT,
+	subject_id TEXT,
+	old_url TEXT,
+	new_url TEXT,
+	details TEXT NOT NULL DEFAULT '',
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_audit_events_created_at ON audit_events(created_at);
+CREATE INDEX idx_audit_events_actor_id ON audit_events(actor_id);
+CREATE INDEX idx_audit_events_subject_id ON audit_events(subject_id);
+CREATE INDEX idx_audit_events_short_code ON audit_events(short_code);
+
+CREATE TRIGGER audit_events_no_update BEFORE UPDATE ON audit_events
+BEGIN
+	SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+
+CREATE TRIGGER audit_events_no_delete BEFORE DELETE ON audit_events
+BEGIN
+	SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
@@ -0,0 +1,10 @@
+-- Pending ownership transfers. A link has at most one; it is removed when accepted,
+-- declined or cancelled. The recipient may not have logged in yet, so to_user_id is a bare ID.
+CREATE TABLE link_transfers (
+	short_code TEXT PRIMARY KEY,
+	from_user_id TEXT NOT NULL,
+	to_user_id TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	expires_at DATETIME NOT NULL
+);
+CREATE INDEX idx_link_transfers_to_user_id ON link_transfers(to_user_id);
@@ -0,0 +1,9 @@
+-- Set when the link's Discord destination was last set by someone with Manage Server in its guild
+ALTER TABLE url_mappings ADD COLUMN verified_at DATETIME;
+
+-- Guilds each user could manage when they last logged in with the guilds scope, replaced on every login
+CREATE TABLE user_guilds (
+	user_id TEXT NOT NULL,
+	guild_id TEXT NOT NULL,
+	PRIMARY KEY (user_id, guild_id)
+);
@@ -0,0 +1,9 @@
+-- When each session last made a request, for idle timeouts and the sessions page
+ALTER TABLE sessions ADD COLUMN last_seen_at DATETIME;
+-- The browser and approximate network a session was created from, so users can recognise it
+ALTER TABLE sessions ADD COLUMN user_agent TEXT;
+ALTER TABLE sessions ADD COLUMN ip_address TEXT;
+
+-- Existing sessions count as last seen when they were created
+UPDATE sessions SET last_seen_at = created_at;
+CREATE INDEX idx_sessions_last_seen ON sessions(last_seen_at);
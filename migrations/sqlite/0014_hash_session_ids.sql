@@ -0,0 +1,3 @@
+-- Sessions are now looked up by the SHA-256 digest of their token, so a copy of the database
+-- can't be used to log in. Hash the tokens of existing sessions in place so they stay valid.
+UPDATE sessions SET id = sha256_hex(id);
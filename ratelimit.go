@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rate limiter names, each with its own rule in the [rate_limits] config section
+const (
+	LimitRegister = "register"
+	LimitLogin    = "login"
+	LimitRedirect = "redirect"
+	LimitAPI      = "api"
+	LimitNotFound = "not_found"
+	LimitReport   = "report"
+)
+
+// tokenBucket tracks the tokens available to a single client
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter is a token-bucket limiter keyed by client
+type RateLimiter struct {
+	mu      sync.Mutex
+	rate    float64 // tokens added per second
+	burst   float64
+	buckets map[string]*tokenBucket
+}
+
+// NewRateLimiter creates a limiter allowing perMinute requests per minute with the given burst
+func NewRateLimiter(rule RateLimitRule) *RateLimiter {
+	return &RateLimiter{
+		rate:    rule.PerMinute / 60,
+		burst:   float64(rule.Burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// refill returns the key's bucket topped up for the time elapsed since it was last used.
+// Callers must hold rl.mu.
+func (rl *RateLimiter) refill(key string, now time.Time) *tokenBucket {
+	bucket, ok := rl.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: rl.burst, last: now}
+		rl.buckets[key] = bucket
+		return bucket
+	}
+
+	elapsed := now.Sub(bucket.last).Seconds()
+	bucket.tokens = math.Min(rl.burst, bucket.tokens+elapsed*rl.rate)
+	bucket.last = now
+	return bucket
+}
+
+// retryAfter returns how long until the bucket holds a whole token. Callers must hold rl.mu.
+func (rl *RateLimiter) retryAfter(bucket *tokenBucket) time.Duration {
+	if rl.rate <= 0 {
+		return time.Hour
+	}
+	return time.Duration((1 - bucket.tokens) / rl.rate * float64(time.Second))
+}
+
+// Allow consumes a token for key, reporting whether one was available and, if not,
+// how long the client should wait
+func (rl *RateLimiter) Allow(key string) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	bucket := rl.refill(key, time.Now())
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	return false, rl.retryAfter(bucket)
+}
+
+// Exhausted reports whether key has no tokens left, without consuming one
+func (rl *RateLimiter) Exhausted(key string) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	bucket := rl.refill(key, time.Now())
+	if bucket.tokens >= 1 {
+		return false, 0
+	}
+	return true, rl.retryAfter(bucket)
+}
+
+// Prune forgets clients whose buckets have refilled completely, returning how many were removed
+func (rl *RateLimiter) Prune() int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	removed := 0
+	for key := range rl.buckets {
+		if rl.refill(key, now).tokens >= rl.burst {
+			delete(rl.buckets, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// newRateLimiters creates one limiter per configured rule
+func newRateLimiters(config *Config) map[string]*RateLimiter {
+	limiters := make(map[string]*RateLimiter)
+	for _, name := range []string{LimitRegister, LimitLogin, LimitRedirect, LimitAPI, LimitNotFound, LimitReport} {
+		limiters[name] = NewRateLimiter(config.GetRateLimitRule(name))
+	}
+	return limiters
+}
+
+// pruneRateLimitersJob drops idle clients from every limiter so memory stays bounded
+func (s *Server) pruneRateLimitersJob(ctx context.Context) error {
+	for _, limiter := range s.limiters {
+		limiter.Prune()
+	}
+	return nil
+}
+
+// rateLimitKey identifies the client a request is counted against: the user for
+// authenticated requests, otherwise the client IP
+func (s *Server) rateLimitKey(r *http.Request) string {
+	if user, err := s.getCurrentUser(r); err == nil {
+		return "user:" + user.ID
+	}
+	return "ip:" + s.clientIP(r)
+}
+
+// clientIP returns the IP address of the client, honouring proxy headers only when configured to.
+// Clients can send their own X-Forwarded-For, so only the entries our proxies appended to the end
+// of it are believed: with one proxy, the rightmost entry is the address it saw.
+func (s *Server) clientIP(r *http.Request) string {
+	if s.config != nil && s.config.Server.TrustProxy {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			hops := strings.Split(strings.Join(forwarded, ","), ",")
+			index := len(hops) - s.config.GetTrustedProxyHops()
+			if index < 0 {
+				index = 0
+			}
+			if ip := net.ParseIP(strings.TrimSpace(hops[index])); ip != nil {
+				return ip.String()
+			}
+		} else if realIP := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); realIP != nil {
+			return realIP.String()
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rateLimited wraps a handler with the named limiter, keyed by user or client IP
+func (s *Server) rateLimited(name string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.allowRequest(w, r, name, s.rateLimitKey(r)) {
+			return
+		}
+		next(w, r)
+	}
+}
+
+// allowRequest consumes a token from the named limiter, writing a 429 response if none is left
+func (s *Server) allowRequest(w http.ResponseWriter, r *http.Request, name, key string) bool {
+	limiter, ok := s.limiters[name]
+	if !ok || s.config.RateLimits.Disabled {
+		return true
+	}
+
+	allowed, retryAfter := limiter.Allow(key)
+	if !allowed {
+		s.renderRateLimited(w, r, retryAfter)
+	}
+	return allowed
+}
+
+// renderRateLimited writes a 429 response in the format the client expects
+func (s *Server) renderRateLimited(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		s.writeAPIError(w, http.StatusTooManyRequests, "rate_limited",
+			fmt.Sprintf("Too many requests, retry in %d seconds", seconds))
+		return
+	}
+
+	s.renderError(w, http.StatusTooManyRequests, "Slow Down",
+		"You're making requests too quickly.",
+		fmt.Sprintf("Please wait %d seconds and try again.", seconds))
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		trustProxy bool
+		hops       int
+		forwarded  []string
+		realIP     string
+		want       string
+	}{
+		{name: "direct", want: "192.0.2.1"},
+		{name: "proxy headers ignored when untrusted", forwarded: []string{"203.0.113.9"}, realIP: "203.0.113.8", want: "192.0.2.1"},
+		{name: "single proxy", trustProxy: true, forwarded: []string{"203.0.113.9"}, want: "203.0.113.9"},
+		{name: "spoofed entries before the proxy's", trustProxy: true, forwarded: []string{"10.0.0.1, 10.0.0.2, 203.0.113.9"}, want: "203.0.113.9"},
+		{name: "repeated headers", trustProxy: true, forwarded: []string{"10.0.0.1", "203.0.113.9"}, want: "203.0.113.9"},
+		{name: "two proxies", trustProxy: true, hops: 2, forwarded: []string{"10.0.0.1, 203.0.113.9, 198.51.100.7"}, want: "203.0.113.9"},
+		{name: "fewer entries than hops", trustProxy: true, hops: 3, forwarded: []string{"203.0.113.9"}, want: "203.0.113.9"},
+		{name: "ipv6", trustProxy: true, forwarded: []string{"2001:db8::1"}, want: "2001:db8::1"},
+		{name: "garbage", trustProxy: true, forwarded: []string{"not-an-ip"}, want: "192.0.2.1"},
+		{name: "real ip", trustProxy: true, realIP: "203.0.113.8", want: "203.0.113.8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{}
+			config.Server.TrustProxy = tt.trustProxy
+			config.Server.TrustedProxyHops = tt.hops
+			s := &Server{config: config}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			for _, value := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			if got := s.clientIP(req); got != tt.want {
+				t.Errorf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Report statuses
+const (
+	ReportOpen      = "open"
+	ReportDismissed = "dismissed"
+	ReportActioned  = "actioned"
+)
+
+// reportDetailsLimit caps the free text a reporter can send
+const reportDetailsLimit = 1000
+
+// ReportReason is a category a reporter can pick
+type ReportReason struct {
+	Value string
+	Label string
+}
+
+// Reason categories offered on the report form
+var reportReasons = []ReportReason{
+	{Value: "scam", Label: "Scam or phishing"},
+	{Value: "nsfw", Label: "NSFW content"},
+	{Value: "malware", Label: "Malware or malicious downloads"},
+	{Value: "harassment", Label: "Harassment or hate"},
+	{Value: "impersonation", Label: "Impersonating another community"},
+	{Value: "other", Label: "Something else"},
+}
+
+// validReportReason reports whether value is one of the offered categories
+func validReportReason(value string) bool {
+	for _, reason := range reportReasons {
+		if reason.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+// reportLabel returns the human readable label for a reason category
+func reportLabel(value string) string {
+	for _, reason := range reportReasons {
+		if reason.Value == value {
+			return reason.Label
+		}
+	}
+	return value
+}
+
+// siteURL returns the absolute URL of the main site, derived from the OAuth redirect URI
+func (s *Server) siteURL() string {
+	return strings.TrimSuffix(s.config.GetRedirectURI(), "/auth/callback")
+}
+
+// redirectToReport sends <code>.domain/report to the report form on the main site
+func (s *Server) redirectToReport(w http.ResponseWriter, r *http.Request, shortCode string) {
+	query := url.Values{"short_code": {strings.ToLower(shortCode)}}
+	http.Redirect(w, r, s.siteURL()+"/report?"+query.Encode(), http.StatusFound)
+}
+
+// HandleReport shows and accepts the public "report this link" form
+func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.renderReportPage(w, r, strings.ToLower(strings.TrimSpace(r.URL.Query().Get("short_code"))), "", "", "", false)
+	case http.MethodPost:
+		// Reporters don't need an account, so they are always counted by IP
+		if !s.allowRequest(w, r, LimitReport, "ip:"+s.clientIP(r)) {
+			return
+		}
+		s.handleReportSubmit(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleReportSubmit files a report against a short code
+func (s *Server) handleReportSubmit(w http.ResponseWriter, r *http.Request) {
+	shortCode := strings.ToLower(strings.TrimSpace(r.FormValue("short_code")))
+	reason := r.FormValue("reason")
+	details := strings.TrimSpace(r.FormValue("details"))
+
+	if shortCode == "" {
+		s.renderReportPage(w, r, shortCode, reason, details, "Please enter the short code you want to report.", false)
+		return
+	}
+	if !validReportReason(reason) {
+		s.renderReportPage(w, r, shortCode, reason, details, "Please choose a reason for the report.", false)
+		return
+	}
+	if len(details) > reportDetailsLimit {
+		s.renderReportPage(w, r, shortCode, reason, details,
+			fmt.Sprintf("Please keep the details under %d characters.", reportDetailsLimit), false)
+		return
+	}
+
+	mapping, err := s.store.GetLinkRecord(r.Context(), shortCode)
+	if errors.Is(err, ErrNotFound) {
+		s.renderReportPage(w, r, shortCode, reason, details,
+			fmt.Sprintf("The short code '%s' was not found.", shortCode), false)
+		return
+	}
+	if err != nil {
+		s.renderError(w, 500, "Database Error", "Failed to look up the link", err.Error())
+		return
+	}
+
+	report := &Report{
+		ShortCode:  mapping.ShortCode,
+		DiscordURL: mapping.DiscordURL,
+		OwnerID:    mapping.OwnerID,
+		Reason:     reason,
+		Details:    details,
+	}
+	if user, err := s.getCurrentUser(r); err == nil {
+		report.ReporterID = &user.ID
+	}
+
+	if err := s.store.CreateReport(r.Context(), report); err != nil {
+		s.renderError(w, 500, "Database Error", "Failed to save your report", err.Error())
+		return
+	}
+	log.Printf("Report filed against %s: %s", shortCode, reason)
+
+	s.renderReportPage(w, r, shortCode, "", "", "", true)
+}
+
+// renderReportPage renders report.html with the form state
+func (s *Server) renderReportPage(w http.ResponseWriter, r *http.Request, shortCode, reason, details, errorMessage string, submitted bool) {
+	data := struct {
+		ShortCode    string
+		Reason       string
+		Details      string
+		Reasons      []ReportReason
+		DetailsLimit int
+		Error        string
+		Submitted    bool
+		BaseDomain   string
+		CSRFToken    string
+	}{
+		ShortCode:    shortCode,
+		Reason:       reason,
+		Details:      details,
+		Reasons:      reportReasons,
+		DetailsLimit: reportDetailsLimit,
+		Error:        errorMessage,
+		Submitted:    submitted,
+		BaseDomain:   s.getBaseDomain(r.Host),
+		CSRFToken:    s.csrfToken(r),
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	if errorMessage != "" {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	err := s.templates.ExecuteTemplate(w, "report.html", data)
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		log.Printf("Template error: %v", err)
+	}
+}
+
+// requireModerator returns the current user if they may work the report queue
+func (s *Server) requireModerator(w http.ResponseWriter, r *http.Request) (*User, bool) {
+	user, err := s.getCurrentUser(r)
+	if err != nil {
+		// Not authenticated, redirect to login
+		s.redirectToLogin(w, r)
+		return nil, false
+	}
+
+	if !s.config.IsModerator(user.ID) {
+		s.renderError(w, 403, "Access Denied",
+			"You need to be a moderator to view this page.",
+			"Moderators are listed by Discord user ID in the server config.")
+		return nil, false
+	}
+	return user, true
+}
+
+// HandleModeration routes the moderator report queue
+func (s *Server) handleModeration(w http.ResponseWriter, r *http.Request, reportsPath string) {
+	user, ok := s.requireModerator(w, r)
+	if !ok {
+		return
+	}
+
+	if reportsPath == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleReportQueue(w, r, user)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	report, ok := s.formReport(w, r)
+	if !ok {
+		return
+	}
+
+	switch reportsPath {
+	case "dismiss":
+		s.handleDismissReport(w, r, user, report)
+	case "disable-link":
+		s.handleReportDisableLink(w, r, user, report)
+	case "ban-owner":
+		s.handleReportBanOwner(w, r, user, report)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// HandleReportQueue lists reports with the requested status, open ones by default
+func (s *Server) handleReportQueue(w http.ResponseWriter, r *http.Request, user *User) {
+	status := r.URL.Query().Get("status")
+	if status != ReportDismissed && status != ReportActioned {
+		status = ReportOpen
+	}
+
+	reports, err := s.store.ListReports(r.Context(), status, adminListLimit)
+	if err != nil {
+		s.renderError(w, 500, "Database Error", "Failed to load reports", err.Error())
+		return
+	}
+	for i := range reports {
+		reports[i].CreatedAt = formatTimestamp(reports[i].CreatedAt)
+		reports[i].Reason = reportLabel(reports[i].Reason)
+		if reports[i].ResolvedAt != nil {
+			resolved := formatTimestamp(*reports[i].ResolvedAt)
+			reports[i].ResolvedAt = &resolved
+		}
+	}
+
+	page := adminPage{
+		User:       user,
+		IsAdmin:    s.config.IsAdmin(user.ID),
+		Tab:        "reports",
+		Status:     status,
+		Reports:    reports,
+		Limit:      adminListLimit,
+		BaseDomain: s.getBaseDomain(r.Host),
+		CSRFToken:  s.csrfToken(r),
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	err = s.templates.ExecuteTemplate(w, "admin.html", page)
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		log.Printf("Template error: %v", err)
+	}
+}
+
+// formReport loads the open report named by the report_id form field
+func (s *Server) formReport(w http.ResponseWriter, r *http.Request) (*Report, bool) {
+	reportID, err := strconv.Atoi(r.FormValue("report_id"))
+	if err != nil {
+		http.Error(w, "Invalid report ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	report, err := s.store.GetReport(r.Context(), reportID)
+	if err == nil && report.Status != ReportOpen {
+		err = ErrNotFound
+	}
+	if err != nil {
+		s.renderAdminError(w, err, "That report was not found or has already been resolved.", "Failed to load report")
+		return nil, false
+	}
+	return report, true
+}
+
+// HandleDismissReport closes a report without acting on the link
+func (s *Server) handleDismissReport(w http.ResponseWriter, r *http.Request, moderator *User, report *Report) {
+	if err := s.store.ResolveReport(r.Context(), report.ID, ReportDismissed, moderator.ID); err != nil {
+		s.renderAdminError(w, err, "That report has already been resolved.", "Failed to dismiss report")
+		return
+	}
+	log.Printf("Moderator %s dismissed report %d against %s", moderator.ID, report.ID, report.ShortCode)
+
+	event := newAuditEvent(moderator.ID, AuditReportDismiss, report.ShortCode)
+	event.SubjectID = report.OwnerID
+	event.Details = fmt.Sprintf("report #%d", report.ID)
+	s.audit(r.Context(), event)
+
+	http.Redirect(w, r, "/admin/reports", http.StatusFound)
+}
+
+// HandleReportDisableLink disables the reported link and closes every open report against it
+func (s *Server) handleReportDisableLink(w http.ResponseWriter, r *http.Request, moderator *User, report *Report) {
+	if err := s.store.SetLinkDisabled(r.Context(), report.ShortCode, true); err != nil {
+		s.renderAdminError(w, err,
+			fmt.Sprintf("The short code '%s' no longer exists.", report.ShortCode), "Failed to disable link")
+		return
+	}
+	s.cache.Invalidate(report.ShortCode)
+
+	event := newAuditEvent(moderator.ID, AuditReportDisableLink, report.ShortCode)
+	event.SubjectID = report.OwnerID
+	event.Details = fmt.Sprintf("report #%d", report.ID)
+	s.audit(r.Context(), event)
+
+	if _, err := s.store.ResolveLinkReports(r.Context(), report.ShortCode, ReportActioned, moderator.ID); err != nil {
+		s.renderError(w, 500, "Database Error", "The link was disabled but its reports could not be closed", err.Error())
+		return
+	}
+	log.Printf("Moderator %s disabled link %s after report %d", moderator.ID, report.ShortCode, report.ID)
+
+	http.Redirect(w, r, "/admin/reports", http.StatusFound)
+}
+
+// HandleReportBanOwner disables the owner of the reported link along with every link they own.
+// The link may have been transferred since it was reported, so its current owner is banned.
+func (s *Server) handleReportBanOwner(w http.ResponseWriter, r *http.Request, moderator *User, report *Report) {
+	mapping, err := s.store.GetLinkRecord(r.Context(), report.ShortCode)
+	if err != nil {
+		s.renderAdminError(w, err,
+			fmt.Sprintf("The short code '%s' no longer exists.", report.ShortCode), "Failed to load reported link")
+		return
+	}
+	if mapping.OwnerID == nil {
+		http.Error(w, "This link has no owner to ban", http.StatusBadRequest)
+		return
+	}
+	ownerID := *mapping.OwnerID
+
+	if s.config.IsModerator(ownerID) {
+		http.Error(w, "Moderators and admins can't be banned from the report queue", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.SetUserDisabled(r.Context(), ownerID, true); err != nil {
+		s.renderAdminError(w, err, fmt.Sprintf("The user '%s' was not found.", ownerID), "Failed to ban user")
+		return
+	}
+
+	disabled, err := s.store.DisableUserLinks(r.Context(), ownerID)
+	if err != nil {
+		s.renderError(w, 500, "Database Error", "The user was banned but their links could not be disabled", err.Error())
+		return
+	}
+	// Any of the owner's links may be cached, so start afresh
+	s.cache.Clear()
+
+	event := newAuditEvent(moderator.ID, AuditReportBanOwner, report.ShortCode)
+	event.SubjectID = &ownerID
+	event.Details = fmt.Sprintf("report #%d, disabled %d links", report.ID, disabled)
+	s.audit(r.Context(), event)
+
+	if _, err := s.store.ResolveLinkReports(r.Context(), report.ShortCode, ReportActioned, moderator.ID); err != nil {
+		s.renderError(w, 500, "Database Error", "The user was banned but their reports could not be closed", err.Error())
+		return
+	}
+	log.Printf("Moderator %s banned user %s and disabled %d links after report %d", moderator.ID, ownerID, disabled, report.ID)
+
+	http.Redirect(w, r, "/admin/reports", http.StatusFound)
+}
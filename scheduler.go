@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// JobFunc is the work performed by a scheduled job
+type JobFunc func(ctx context.Context) error
+
+// JobStatus reports the outcome of a job's most recent run
+type JobStatus struct {
+	Name         string        `json:"name"`
+	Interval     time.Duration `json:"interval"`
+	Running      bool          `json:"running"`
+	LastRun      *time.Time    `json:"last_run"`
+	LastDuration time.Duration `json:"last_duration"`
+	LastError    string        `json:"last_error"`
+	Runs         int           `json:"runs"`
+}
+
+// MarshalJSON renders durations in human readable form for operators
+func (js JobStatus) MarshalJSON() ([]byte, error) {
+	type plain JobStatus
+	return json.Marshal(struct {
+		plain
+		Interval     string `json:"interval"`
+		LastDuration string `json:"last_duration"`
+	}{
+		plain:        plain(js),
+		Interval:     js.Interval.String(),
+		LastDuration: js.LastDuration.String(),
+	})
+}
+
+// job is a named function run periodically by the scheduler
+type job struct {
+	name     string
+	interval time.Duration
+	fn       JobFunc
+}
+
+// Scheduler runs named jobs at fixed intervals in background goroutines
+type Scheduler struct {
+	mu      sync.Mutex
+	jobs    []job
+	status  map[string]*JobStatus
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	started bool
+}
+
+// NewScheduler creates an empty scheduler
+func NewScheduler() *Scheduler {
+	return &Scheduler{status: make(map[string]*JobStatus)}
+}
+
+// Register adds a job to the scheduler. Jobs with a non-positive interval are disabled.
+// Register must be called before Start.
+func (sc *Scheduler) Register(name string, interval time.Duration, fn JobFunc) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if interval <= 0 {
+		log.Printf("Job %s disabled", name)
+		return
+	}
+
+	sc.jobs = append(sc.jobs, job{name: name, interval: interval, fn: fn})
+	sc.status[name] = &JobStatus{Name: name, Interval: interval}
+}
+
+// Start launches every registered job. Each job runs once immediately and then on its interval.
+func (sc *Scheduler) Start() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.started {
+		return
+	}
+	sc.started = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sc.cancel = cancel
+
+	for _, j := range sc.jobs {
+		sc.wg.Add(1)
+		go sc.loop(ctx, j)
+	}
+}
+
+// Stop cancels all jobs and waits for any in-progress runs to finish
+func (sc *Scheduler) Stop() {
+	sc.mu.Lock()
+	cancel := sc.cancel
+	sc.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	sc.wg.Wait()
+}
+
+// Status returns a snapshot of every job's status, sorted by name
+func (sc *Scheduler) Status() []JobStatus {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	statuses := make([]JobStatus, 0, len(sc.status))
+	for _, status := range sc.status {
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// loop runs a single job until the context is cancelled
+func (sc *Scheduler) loop(ctx context.Context, j job) {
+	defer sc.wg.Done()
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		sc.run(ctx, j)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// run executes a job once and records its outcome
+func (sc *Scheduler) run(ctx context.Context, j job) {
+	sc.mu.Lock()
+	sc.status[j.name].Running = true
+	sc.mu.Unlock()
+
+	started := time.Now()
+	err := j.fn(ctx)
+	duration := time.Since(started)
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	status := sc.status[j.name]
+	status.Running = false
+	status.LastRun = &started
+	status.LastDuration = duration
+	status.Runs++
+	status.LastError = ""
+	if err != nil {
+		status.LastError = err.Error()
+		log.Printf("Job %s failed: %v", j.name, err)
+	}
+}
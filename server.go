@@ -10,15 +10,16 @@ import (
 )
 
 // InitServer initializes a new server instance with all dependencies
-func InitServer(dbPath string, config *Config) (*Server, error) {
-	// Open database connection
-	db, err := OpenDatabase(dbPath)
-	if err != nil {
+func InitServer(store Store, config *Config) (*Server, error) {
+	if err := config.validateRedirectMode(); err != nil {
 		return nil, err
 	}
 
 	// Load templates
-	templates, err := template.ParseGlob("assets/*.html")
+	templates, err := template.New("").Funcs(template.FuncMap{
+		"shortLink": func(shortCode, baseDomain string) string { return shortLink(config, shortCode, baseDomain) },
+		"pathLinks": func() bool { return !config.SubdomainRedirects() },
+	}).ParseGlob("assets/*.html")
 	if err != nil {
 		return nil, fmt.Errorf("failed to load templates: %w", err)
 	}
@@ -26,18 +27,36 @@ func InitServer(dbPath string, config *Config) (*Server, error) {
 	// Initialize Discord OAuth client
 	redirectURI := config.GetRedirectURI()
 
+	scopes := []string{disgoauth.ScopeIdentify} // identify scope provides: id, username, global_name, avatar, discriminator
+	if config.GuildVerificationEnabled() {
+		// guilds lets the callback see which servers the user can manage
+		scopes = append(scopes, disgoauth.ScopeGuilds)
+	}
+
 	discordAuth := disgoauth.Init(&disgoauth.Client{
 		ClientID:     config.Client.ID,
 		ClientSecret: config.Client.Secret,
 		RedirectURI:  redirectURI,
-		Scopes:       []string{disgoauth.ScopeIdentify}, // identify scope provides: id, username, avatar, discriminator
+		Scopes:       scopes,
 	})
 
+	codePolicy, err := NewShortCodePolicy(config)
+	if err != nil {
+		return nil, err
+	}
+
 	server := &Server{
-		db:          db,
+		store:       store,
 		templates:   templates,
 		discordAuth: discordAuth,
 		config:      config,
+		limiters:    newRateLimiters(config),
+		cache:       NewRedirectCache(config.GetCacheSize(), config.GetCacheTTL(), config.GetCacheNegativeTTL()),
+		discord:     NewDiscordClient(config.GetDiscordAPIBaseURL()),
+		codePolicy:  codePolicy,
+		stateKey:    oauthStateKey(config),
+		csrfKey:     csrfKey(config),
+		oauthStates: newOAuthStateLedger(),
 	}
 
 	// Initialize database schema
@@ -76,6 +95,15 @@ func (s *Server) getBaseDomain(host string) string {
 	return host
 }
 
+// shortLink returns the address of a short code, without a scheme, in the form the redirect
+// mode serves. Subdomains are preferred when both work.
+func shortLink(config *Config, shortCode, baseDomain string) string {
+	if config.SubdomainRedirects() {
+		return shortCode + "." + baseDomain
+	}
+	return baseDomain + "/" + shortCode
+}
+
 // extractSubdomain extracts the subdomain from the Host header
 // Returns empty string if no subdomain or if subdomain is www
 func (s *Server) extractSubdomain(host string) string {
@@ -104,19 +132,49 @@ func (s *Server) extractSubdomain(host string) string {
 	return strings.ToLower(subdomain)
 }
 
+// routeNames are the first path segments of the site's own pages. Path redirects never
+// shadow them, and they can't be registered as short codes.
+var routeNames = []string{
+	"register", "dashboard", "delete", "edit", "renew", "expiry", "transfers", "members",
+	"sessions", "tokens", "report", "admin", "status", "api", "auth", "assets",
+}
+
+// isRouteName reports whether a path segment belongs to one of the site's own pages
+func isRouteName(segment string) bool {
+	for _, name := range routeNames {
+		if segment == name {
+			return true
+		}
+	}
+	return false
+}
+
 // ServeHTTP implements http.Handler for routing
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	path := strings.TrimPrefix(r.URL.Path, "/")
 
 	// Extract subdomain from Host header
-	subdomain := s.extractSubdomain(r.Host)
+	var subdomain string
+	if s.config.SubdomainRedirects() {
+		subdomain = s.extractSubdomain(r.Host)
+	}
 
 	// If we have a subdomain, treat it as a shortcode redirect
 	if subdomain != "" {
+		// <code>.domain/report opens the report form for that code
+		if path == "report" {
+			s.redirectToReport(w, r, subdomain)
+			return
+		}
 		s.handleRedirect(w, r, subdomain)
 		return
 	}
 
+	// Form posts must come from this site's own pages
+	if !s.checkCSRF(w, r) {
+		return
+	}
+
 	// Handle root path (dashboard)
 	if path == "" {
 		s.handleDashboard(w, r)
@@ -137,7 +195,9 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// Handle authentication routes
 	if strings.HasPrefix(path, "auth/") {
-		s.handleAuth(w, r, strings.TrimPrefix(path, "auth/"))
+		s.rateLimited(LimitLogin, func(w http.ResponseWriter, r *http.Request) {
+			s.handleAuth(w, r, strings.TrimPrefix(path, "auth/"))
+		})(w, r)
 		return
 	}
 
@@ -153,6 +213,93 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Handle edit (requires auth)
+	if path == "edit" {
+		s.handleEdit(w, r)
+		return
+	}
+
+	// Handle renew and expiry changes (requires auth)
+	if path == "renew" {
+		s.handleRenew(w, r)
+		return
+	}
+
+	if path == "expiry" {
+		s.handleExpiry(w, r)
+		return
+	}
+
+	// Handle link ownership transfers (requires auth)
+	if strings.HasPrefix(path, "transfers/") {
+		s.handleTransfers(w, r, strings.TrimPrefix(path, "transfers/"))
+		return
+	}
+
+	// Handle link member management (requires auth)
+	if path == "members" || strings.HasPrefix(path, "members/") {
+		s.handleMembers(w, r, strings.TrimPrefix(strings.TrimPrefix(path, "members"), "/"))
+		return
+	}
+
+	// Handle login session management (requires auth)
+	if path == "sessions" || strings.HasPrefix(path, "sessions/") {
+		s.handleSessions(w, r, strings.TrimPrefix(strings.TrimPrefix(path, "sessions"), "/"))
+		return
+	}
+
+	// Handle personal access token management (requires auth)
+	if strings.HasPrefix(path, "tokens/") {
+		s.handleTokens(w, r, strings.TrimPrefix(path, "tokens/"))
+		return
+	}
+
+	// Handle public abuse reports
+	if path == "report" {
+		s.handleReport(w, r)
+		return
+	}
+
+	// Handle administration panel (requires admin, or moderator for the report queue)
+	if path == "admin" || strings.HasPrefix(path, "admin/") {
+		s.handleAdmin(w, r, strings.TrimPrefix(strings.TrimPrefix(path, "admin"), "/"))
+		return
+	}
+
+	// Handle background job status (local operators only)
+	if path == "status/jobs" {
+		s.handleJobStatus(w, r)
+		return
+	}
+
+	// Handle redirect cache statistics (local operators only)
+	if path == "status/cache" {
+		s.handleCacheStatus(w, r)
+		return
+	}
+
+	// Handle JSON API (requires token)
+	if strings.HasPrefix(path, "api/") {
+		s.handleAPI(w, r, strings.TrimPrefix(path, "api/"))
+		return
+	}
+
+	// Anything else may be a short code addressed as domain/<code>
+	if s.config.PathRedirects() {
+		shortCode, rest, _ := strings.Cut(strings.TrimSuffix(path, "/"), "/")
+		if looksLikeShortCode(shortCode) && !isRouteName(strings.ToLower(shortCode)) {
+			switch rest {
+			case "":
+				s.handleRedirect(w, r, shortCode)
+				return
+			case "report":
+				// domain/<code>/report opens the report form for that code
+				s.redirectToReport(w, r, shortCode)
+				return
+			}
+		}
+	}
+
 	// If no subdomain and path doesn't match any route, show 404
 	http.NotFound(w, r)
 }
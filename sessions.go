@@ -0,0 +1,245 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxUserAgentLength caps the user agent stored with a session
+const maxUserAgentLength = 256
+
+// Browsers and operating systems recognised in user agents, most specific first since most
+// browsers also claim to be the ones they are built on
+var (
+	userAgentBrowsers = []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+	}
+	userAgentSystems = []struct{ token, name string }{
+		{"Android", "Android"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	}
+)
+
+// truncateUserAgent limits a user agent to what is worth storing
+func truncateUserAgent(userAgent string) string {
+	if len(userAgent) > maxUserAgentLength {
+		return userAgent[:maxUserAgentLength]
+	}
+	return userAgent
+}
+
+// describeUserAgent summarises a user agent as browser and operating system, falling back to
+// the raw string for anything unrecognised
+func describeUserAgent(userAgent string) string {
+	var browser, system string
+	for _, b := range userAgentBrowsers {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	for _, sys := range userAgentSystems {
+		if strings.Contains(userAgent, sys.token) {
+			system = sys.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && system != "":
+		return browser + " on " + system
+	case browser != "":
+		return browser
+	case userAgent == "":
+		return "Unknown browser"
+	}
+	return userAgent
+}
+
+// Browser describes the browser a session was created in
+func (session Session) Browser() string {
+	return describeUserAgent(session.UserAgent)
+}
+
+// approximateIP reduces an address to its network, enough for users to recognise where a
+// session came from without keeping their exact address
+func approximateIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: parsed.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}).String()
+}
+
+// describeTimeout describes a session timeout in the largest sensible unit
+func describeTimeout(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%d days", int(d.Hours()/24))
+	case d >= 2*time.Hour:
+		return fmt.Sprintf("%d hours", int(d.Hours()))
+	}
+	return d.String()
+}
+
+// sessionRef returns a short stable identifier for a session to put in a page
+func sessionRef(sessionID string) string {
+	sum := sha256.Sum256([]byte(sessionID))
+	return hex.EncodeToString(sum[:8])
+}
+
+// HandleSessions routes login session management requests
+func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request, sessionsPath string) {
+	// Check if user is authenticated
+	user, err := s.getCurrentUser(r)
+	if err != nil {
+		// Not authenticated, redirect to login
+		s.redirectToLogin(w, r)
+		return
+	}
+
+	// getCurrentUser succeeded, so the cookie is present
+	token, _ := sessionToken(r)
+	currentID := hashSessionToken(token)
+
+	if sessionsPath == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleSessionsPage(w, r, user, currentID)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch sessionsPath {
+	case "revoke":
+		s.handleSessionRevoke(w, r, user, currentID)
+	case "revoke-others":
+		s.handleSessionRevokeOthers(w, r, user, currentID)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// HandleSessionsPage lists the user's active logins
+func (s *Server) handleSessionsPage(w http.ResponseWriter, r *http.Request, user *User, currentID string) {
+	sessions, err := s.store.ListUserSessions(r.Context(), user.ID, s.sessionIdleSince())
+	if err != nil {
+		s.renderError(w, 500, "Database Error", "Failed to retrieve sessions", err.Error())
+		return
+	}
+	for i := range sessions {
+		sessions[i].Ref = sessionRef(sessions[i].ID)
+		sessions[i].Current = sessions[i].ID == currentID
+		sessions[i].CreatedAt = formatTimestamp(sessions[i].CreatedAt)
+		sessions[i].LastSeenAt = formatTimestamp(sessions[i].LastSeenAt)
+		sessions[i].ExpiresAt = formatTimestamp(sessions[i].ExpiresAt)
+	}
+
+	data := struct {
+		User        *User
+		Sessions    []Session
+		IdleTimeout string
+		CSRFToken   string
+	}{
+		User:      user,
+		Sessions:  sessions,
+		CSRFToken: s.csrfToken(r),
+	}
+	if idle := s.config.GetSessionIdleTimeout(); idle > 0 {
+		data.IdleTimeout = describeTimeout(idle)
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	err = s.templates.ExecuteTemplate(w, "sessions.html", data)
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		log.Printf("Template error: %v", err)
+	}
+}
+
+// HandleSessionRevoke signs out one of the user's other sessions
+func (s *Server) handleSessionRevoke(w http.ResponseWriter, r *http.Request, user *User, currentID string) {
+	ref := r.FormValue("session")
+	if ref == "" {
+		http.Error(w, "Session is required", http.StatusBadRequest)
+		return
+	}
+
+	sessions, err := s.store.ListUserSessions(r.Context(), user.ID, s.sessionIdleSince())
+	if err != nil {
+		s.renderError(w, 500, "Database Error", "Failed to retrieve sessions", err.Error())
+		return
+	}
+
+	for _, session := range sessions {
+		if sessionRef(session.ID) != ref {
+			continue
+		}
+		if err := s.store.DeleteSession(r.Context(), session.ID); err != nil {
+			s.renderError(w, 500, "Database Error", "Failed to sign out session", err.Error())
+			return
+		}
+
+		event := newAuditEvent(user.ID, AuditSessionRevoke, "")
+		event.Details = session.Browser()
+		s.audit(r.Context(), event)
+
+		// Signing out the current session is just logging out
+		if session.ID == currentID {
+			s.clearSessionCookie(w, r)
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		http.Redirect(w, r, "/sessions", http.StatusFound)
+		return
+	}
+
+	s.renderError(w, 404, "Session Not Found",
+		"That session has already ended.",
+		"It may have been signed out elsewhere or expired.")
+}
+
+// HandleSessionRevokeOthers signs out every session except the one making the request
+func (s *Server) handleSessionRevokeOthers(w http.ResponseWriter, r *http.Request, user *User, currentID string) {
+	removed, err := s.store.DeleteOtherSessions(r.Context(), user.ID, currentID)
+	if err != nil {
+		s.renderError(w, 500, "Database Error", "Failed to sign out other sessions", err.Error())
+		return
+	}
+
+	if removed > 0 {
+		event := newAuditEvent(user.ID, AuditSessionRevoke, "")
+		event.Details = fmt.Sprintf("%d other sessions", removed)
+		if removed == 1 {
+			event.Details = "1 other session"
+		}
+		s.audit(r.Context(), event)
+	}
+
+	http.Redirect(w, r, "/sessions", http.StatusFound)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// reservedShortCodes can never be registered because they name infrastructure subdomains
+// that extractSubdomain would otherwise treat as short codes
+var reservedShortCodes = []string{
+	"www", "api", "auth", "mail", "admin", "app", "assets", "static", "status",
+	"dashboard", "register", "login", "logout", "smtp", "imap", "pop", "ftp",
+	"ns1", "ns2", "mx", "cdn", "dev", "staging", "test", "support", "help",
+}
+
+// ShortCodePolicy decides which short codes may be registered
+type ShortCodePolicy struct {
+	MinLength    int
+	MaxLength    int
+	AllowHyphens bool
+	reserved     map[string]bool
+	banned       []string
+}
+
+// NewShortCodePolicy builds the short code policy from the config, loading the banned words file if set
+func NewShortCodePolicy(config *Config) (*ShortCodePolicy, error) {
+	policy := &ShortCodePolicy{
+		MinLength:    config.GetShortCodeMinLength(),
+		MaxLength:    config.GetShortCodeMaxLength(),
+		AllowHyphens: config.ShortCodes.AllowHyphens,
+		reserved:     make(map[string]bool),
+	}
+	if policy.MinLength > policy.MaxLength {
+		return nil, fmt.Errorf("short code min_length %d is greater than max_length %d", policy.MinLength, policy.MaxLength)
+	}
+
+	// Route names are reserved whatever the redirect mode, so switching to path redirects
+	// later can't leave a registered code hidden behind a page
+	reserved := append(append(reservedShortCodes, routeNames...), config.ShortCodes.Reserved...)
+	for _, word := range reserved {
+		policy.reserved[strings.ToLower(strings.TrimSpace(word))] = true
+	}
+
+	if path := config.ShortCodes.BannedWordsFile; path != "" {
+		banned, err := loadBannedWords(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load banned words: %w", err)
+		}
+		policy.banned = banned
+	}
+
+	return policy, nil
+}
+
+// loadBannedWords reads one lowercase word per line, skipping blank lines and # comments
+func loadBannedWords(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		word := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		words = append(words, word)
+	}
+	return words, scanner.Err()
+}
+
+// Validate checks a lowercased short code against the policy
+func (p *ShortCodePolicy) Validate(shortCode string) error {
+	if shortCode == "" {
+		return errors.New("Short code is required")
+	}
+
+	if len(shortCode) < p.MinLength {
+		return fmt.Errorf("Short code must be at least %d characters", p.MinLength)
+	}
+	if len(shortCode) > p.MaxLength {
+		return fmt.Errorf("Short code must be %d characters or less", p.MaxLength)
+	}
+
+	for _, r := range shortCode {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || (r == '-' && p.AllowHyphens) {
+			continue
+		}
+		return fmt.Errorf("Short code can only contain %s", p.allowedCharacters())
+	}
+
+	// Codes are used as DNS labels, which can't start or end with a hyphen
+	if strings.HasPrefix(shortCode, "-") || strings.HasSuffix(shortCode, "-") {
+		return errors.New("Short code can't start or end with a hyphen")
+	}
+
+	if p.reserved[shortCode] {
+		return fmt.Errorf("The short code '%s' is reserved", shortCode)
+	}
+	for _, word := range p.banned {
+		if strings.Contains(shortCode, word) {
+			return errors.New("This short code isn't allowed")
+		}
+	}
+
+	return nil
+}
+
+// looksLikeShortCode reports whether a path segment could be a short code under any policy,
+// so path redirects don't look up (and count as misses) things like favicon.ico
+func looksLikeShortCode(segment string) bool {
+	if segment == "" || len(segment) > 63 {
+		return false
+	}
+	for _, r := range strings.ToLower(segment) {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// allowedCharacters describes the characters a short code may contain
+func (p *ShortCodePolicy) allowedCharacters() string {
+	if p.AllowHyphens {
+		return "letters, numbers and hyphens"
+	}
+	return "letters and numbers"
+}
+
+// Description summarizes the policy for the registration form
+func (p *ShortCodePolicy) Description() string {
+	length := fmt.Sprintf("%d to %d characters", p.MinLength, p.MaxLength)
+	if p.MinLength == p.MaxLength {
+		length = fmt.Sprintf("exactly %d characters", p.MaxLength)
+	}
+	return fmt.Sprintf("Only %s allowed, %s", p.allowedCharacters(), length)
+}
+
+// HTMLPattern returns the pattern attribute used by the registration form
+func (p *ShortCodePolicy) HTMLPattern() string {
+	chars := "a-zA-Z0-9"
+	if p.AllowHyphens {
+		chars += "\\-"
+	}
+	return fmt.Sprintf("[%s]{%d,%d}", chars, p.MinLength, p.MaxLength)
+}
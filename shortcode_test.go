@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestShortCodePolicyValidate(t *testing.T) {
+	bannedWords := filepath.Join(t.TempDir(), "banned.txt")
+	if err := os.WriteFile(bannedWords, []byte("# Words no code may contain\n\n  Spam \nSCAM\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &Config{}
+	config.ShortCodes.MinLength = 3
+	config.ShortCodes.MaxLength = 8
+	config.ShortCodes.AllowHyphens = true
+	config.ShortCodes.Reserved = []string{" Premium "}
+	config.ShortCodes.BannedWordsFile = bannedWords
+
+	policy, err := NewShortCodePolicy(config)
+	if err != nil {
+		t.Fatalf("NewShortCodePolicy: %v", err)
+	}
+	noHyphens := *policy
+	noHyphens.AllowHyphens = false
+
+	tests := []struct {
+		name    string
+		policy  *ShortCodePolicy
+		code    string
+		wantErr string
+	}{
+		{name: "valid", policy: policy, code: "abc123"},
+		{name: "empty", policy: policy, code: "", wantErr: "required"},
+
+		// Length
+		{name: "shortest", policy: policy, code: "abc"},
+		{name: "too short", policy: policy, code: "ab", wantErr: "at least 3"},
+		{name: "longest", policy: policy, code: "abcdefgh"},
+		{name: "too long", policy: policy, code: "abcdefghi", wantErr: "8 characters or less"},
+
+		// Characters and hyphens
+		{name: "uppercase", policy: policy, code: "ABC", wantErr: "can only contain"},
+		{name: "symbol", policy: policy, code: "ab_c", wantErr: "can only contain"},
+		{name: "inner hyphen", policy: policy, code: "ab-cd"},
+		{name: "leading hyphen", policy: policy, code: "-abc", wantErr: "start or end with a hyphen"},
+		{name: "trailing hyphen", policy: policy, code: "abc-", wantErr: "start or end with a hyphen"},
+		{name: "hyphens not allowed", policy: &noHyphens, code: "ab-cd", wantErr: "letters and numbers"},
+
+		// Reserved names
+		{name: "built-in reserved", policy: policy, code: "www", wantErr: "reserved"},
+		{name: "route name", policy: policy, code: "members", wantErr: "reserved"},
+		{name: "configured reserved", policy: policy, code: "premium", wantErr: "reserved"},
+		{name: "reserved only as a whole", policy: policy, code: "wwwabc"},
+
+		// Banned words, lowercased when loaded, match anywhere in the code
+		{name: "banned word", policy: policy, code: "spam", wantErr: "isn't allowed"},
+		{name: "banned word inside", policy: policy, code: "xxscamxx", wantErr: "isn't allowed"},
+		{name: "banned word across a hyphen", policy: policy, code: "sp-am"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate(tt.code)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate(%q) = %v, want nil", tt.code, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate(%q) = %v, want an error containing %q", tt.code, err, tt.wantErr)
+			}
+		})
+	}
+
+	// Every route is reserved, whatever the redirect mode
+	long := *policy
+	long.MaxLength = 63
+	for _, name := range routeNames {
+		if err := long.Validate(name); err == nil || !strings.Contains(err.Error(), "reserved") {
+			t.Errorf("Validate(%q) = %v, want it reserved", name, err)
+		}
+	}
+}
+
+func TestNewShortCodePolicyErrors(t *testing.T) {
+	config := &Config{}
+	config.ShortCodes.MinLength = 6
+	config.ShortCodes.MaxLength = 4
+	if _, err := NewShortCodePolicy(config); err == nil {
+		t.Error("a min_length above max_length was accepted")
+	}
+
+	config = &Config{}
+	config.ShortCodes.BannedWordsFile = filepath.Join(t.TempDir(), "missing.txt")
+	if _, err := NewShortCodePolicy(config); err == nil {
+		t.Error("a missing banned words file was accepted")
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// Errors returned by every store implementation
+var (
+	// ErrCodeTaken is returned when creating a link whose short code is already registered
+	ErrCodeTaken = errors.New("short code already exists")
+	// ErrNotFound is returned when the requested record does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrNotOwner is returned when a user tries to change a link that belongs to someone else
+	ErrNotOwner = errors.New("link belongs to another user")
+	// ErrLinkDisabled is returned when changing or deleting a link a moderator has disabled
+	ErrLinkDisabled = errors.New("link has been disabled by a moderator")
+)
+
+// LinkStore persists short code to Discord invite mappings
+type LinkStore interface {
+	// CreateLink registers a new short code, failing with ErrCodeTaken if it exists
+	CreateLink(ctx context.Context, shortCode, discordURL, ownerID string, expiry LinkExpiry, invite *DiscordInvite) error
+	// ResolveLink returns the Discord URL of an active (unexpired and not disabled) link
+	ResolveLink(ctx context.Context, shortCode string) (string, error)
+	// GetLink returns an active (unexpired) link
+	GetLink(ctx context.Context, shortCode string) (*URLMapping, error)
+	// GetLinkRecord returns a link whether or not it has expired
+	GetLinkRecord(ctx context.Context, shortCode string) (*URLMapping, error)
+	// ListUserLinks returns every link a user owns or co-manages, newest first, including
+	// expired ones, with the user's role on each
+	ListUserLinks(ctx context.Context, userID string) ([]URLMapping, error)
+	// UpdateLinkURL changes the Discord destination of a link userID may edit, refusing
+	// disabled links with ErrLinkDisabled
+	UpdateLinkURL(ctx context.Context, shortCode, userID, discordURL string, invite *DiscordInvite) error
+	// SetLinkExpiry changes the expiry of a link userID may edit, refusing disabled links with
+	// ErrLinkDisabled
+	SetLinkExpiry(ctx context.Context, shortCode, userID string, expiry LinkExpiry) error
+	// DeleteLink removes a link userID owns or co-owns, refusing disabled links with
+	// ErrLinkDisabled
+	DeleteLink(ctx context.Context, shortCode, userID string) error
+	// PurgeExpiredLinks removes links that expired before the cutoff, keeping disabled ones so
+	// their code stays taken
+	PurgeExpiredLinks(ctx context.Context, cutoff time.Time) (int64, error)
+	// ListLinksToCheck returns up to limit active links whose invite health was last checked
+	// before checkedBefore, never-checked links first
+	ListLinksToCheck(ctx context.Context, checkedBefore time.Time, limit int) ([]URLMapping, error)
+	// SetLinkHealth records the result of an invite health check
+	SetLinkHealth(ctx context.Context, shortCode, health string, checkedAt time.Time) error
+}
+
+// UserStore persists Discord users and their personal access tokens
+type UserStore interface {
+	// UpsertUser creates a user or refreshes their Discord profile
+	UpsertUser(ctx context.Context, user *User) error
+	// CreateAPIToken stores the digest of a new personal access token
+	CreateAPIToken(ctx context.Context, userID, name, tokenHash string) error
+	// ListAPITokens returns a user's personal access tokens, newest first
+	ListAPITokens(ctx context.Context, userID string) ([]APIToken, error)
+	// GetUserByAPIToken returns the enabled owner of a token digest and records that it was used
+	GetUserByAPIToken(ctx context.Context, tokenHash string) (*User, error)
+	// DeleteAPIToken revokes a personal access token owned by userID
+	DeleteAPIToken(ctx context.Context, tokenID int, userID string) error
+	// SetManagedGuilds replaces the guilds a user can manage, as reported by Discord at login
+	SetManagedGuilds(ctx context.Context, userID string, guildIDs []string) error
+	// ManagesGuild reports whether a guild was among those a user could manage at login
+	ManagesGuild(ctx context.Context, userID, guildID string) (bool, error)
+}
+
+// AdminStore gives administrators access to every link and user, regardless of ownership
+type AdminStore interface {
+	// ListAllLinks returns links from every owner matching the query
+	ListAllLinks(ctx context.Context, query AdminQuery) ([]AdminLink, error)
+	// ListUsers returns users matching the query with how many links each owns
+	ListUsers(ctx context.Context, query AdminQuery) ([]UserSummary, error)
+	// GetUser returns a user whether or not they are disabled
+	GetUser(ctx context.Context, userID string) (*User, error)
+	// AdminDeleteLink removes a link whoever owns it
+	AdminDeleteLink(ctx context.Context, shortCode string) error
+	// ReassignLink moves a link to another existing user
+	ReassignLink(ctx context.Context, shortCode, newOwnerID string) error
+	// SetUserDisabled disables or re-enables a user, ending their sessions when disabling
+	SetUserDisabled(ctx context.Context, userID string, disabled bool) error
+	// SetLinkDisabled disables or re-enables any link
+	SetLinkDisabled(ctx context.Context, shortCode string, disabled bool) error
+	// DisableUserLinks disables every link a user owns, returning how many were changed
+	DisableUserLinks(ctx context.Context, userID string) (int64, error)
+}
+
+// TransferStore persists pending ownership transfers. Listings and acceptance ignore offers
+// that have expired or whose link no longer belongs to the user who made them.
+type TransferStore interface {
+	// OfferTransfer offers a link owned by fromUserID to toUserID, replacing any pending offer.
+	// Disabled links are refused with ErrLinkDisabled.
+	OfferTransfer(ctx context.Context, shortCode, fromUserID, toUserID string, expiresAt time.Time) error
+	// ListIncomingTransfers returns pending offers made to a user
+	ListIncomingTransfers(ctx context.Context, userID string) ([]LinkTransfer, error)
+	// ListOutgoingTransfers returns pending offers a user has made
+	ListOutgoingTransfers(ctx context.Context, userID string) ([]LinkTransfer, error)
+	// CancelTransfer withdraws an offer made by fromUserID
+	CancelTransfer(ctx context.Context, shortCode, fromUserID string) (*LinkTransfer, error)
+	// DeclineTransfer refuses an offer made to toUserID
+	DeclineTransfer(ctx context.Context, shortCode, toUserID string) (*LinkTransfer, error)
+	// AcceptTransfer moves the link to toUserID and records it in the audit log, all at once
+	AcceptTransfer(ctx context.Context, shortCode, toUserID string) (*LinkTransfer, error)
+}
+
+// MemberStore persists the users who co-manage links. A link's owner_id always has RoleOwner;
+// other members have the role they were invited with once they accept.
+type MemberStore interface {
+	// LinkRole returns a user's role on a link, or ErrNotOwner if they have none
+	LinkRole(ctx context.Context, shortCode, userID string) (string, error)
+	// InviteMember invites userID to a link inviterID owns or co-owns, replacing any earlier
+	// invitation or role they had; a new role must be accepted again before it takes effect.
+	// Disabled links are refused with ErrLinkDisabled.
+	InviteMember(ctx context.Context, shortCode, inviterID, userID, role string) error
+	// ListLinkMembers returns a link's members and pending invitations, oldest first
+	ListLinkMembers(ctx context.Context, shortCode string) ([]LinkMember, error)
+	// ListMemberInvites returns the invitations a user has not yet accepted
+	ListMemberInvites(ctx context.Context, userID string) ([]LinkMember, error)
+	// AcceptMemberInvite makes a pending invitation to userID take effect
+	AcceptMemberInvite(ctx context.Context, shortCode, userID string) error
+	// RemoveMember removes a member or invitation. Owners may remove anyone; other users
+	// only themselves.
+	RemoveMember(ctx context.Context, shortCode, removerID, userID string) error
+}
+
+// AuditStore persists the append-only audit log. Events can only be added, never changed.
+type AuditStore interface {
+	// RecordAuditEvent appends an event to the audit log
+	RecordAuditEvent(ctx context.Context, event *AuditEvent) error
+	// ListAuditEvents returns events matching the query, newest first
+	ListAuditEvents(ctx context.Context, query AuditQuery) ([]AuditEvent, error)
+}
+
+// ReportStore persists abuse reports and their moderation outcome
+type ReportStore interface {
+	// CreateReport files a new open report
+	CreateReport(ctx context.Context, report *Report) error
+	// ListReports returns reports with the given status, oldest first
+	ListReports(ctx context.Context, status string, limit int) ([]Report, error)
+	// GetReport returns a single report
+	GetReport(ctx context.Context, reportID int) (*Report, error)
+	// ResolveReport closes one open report with the given status
+	ResolveReport(ctx context.Context, reportID int, status, resolvedBy string) error
+	// ResolveLinkReports closes every open report against a short code, returning how many were closed
+	ResolveLinkReports(ctx context.Context, shortCode, status, resolvedBy string) (int64, error)
+}
+
+// SessionStore persists login sessions
+type SessionStore interface {
+	// CreateSession stores a new session for a user by the digest of its token, with the browser and network it came from
+	CreateSession(ctx context.Context, tokenHash, userID string, expiresAt time.Time, userAgent, ipAddress string) error
+	// GetSessionUser returns the user of an unexpired session last seen after idleSince, unless
+	// they are disabled, and records that the session was used
+	GetSessionUser(ctx context.Context, tokenHash string, idleSince time.Time) (*User, error)
+	// ListUserSessions returns a user's unexpired sessions last seen after idleSince, most
+	// recently used first
+	ListUserSessions(ctx context.Context, userID string, idleSince time.Time) ([]Session, error)
+	// DeleteSession removes a session
+	DeleteSession(ctx context.Context, tokenHash string) error
+	// DeleteOtherSessions removes every session of a user except keepTokenHash
+	DeleteOtherSessions(ctx context.Context, userID, keepTokenHash string) (int64, error)
+	// PurgeExpiredSessions removes every expired session and those not seen since idleSince
+	PurgeExpiredSessions(ctx context.Context, idleSince time.Time) (int64, error)
+}
+
+// Store combines every persistence interface the server needs
+type Store interface {
+	LinkStore
+	UserStore
+	SessionStore
+	AdminStore
+	ReportStore
+	TransferStore
+	MemberStore
+	AuditStore
+	io.Closer
+}
+
+// Migrator is implemented by stores whose schema is managed by versioned migrations
+type Migrator interface {
+	// PendingMigrations returns migrations not yet applied, failing if the database is newer than the binary
+	PendingMigrations() ([]Migration, error)
+	// Migrate applies every pending migration, returning how many were applied
+	Migrate() (int, error)
+}
+
+// OpenStore opens the storage backend selected in the config
+func OpenStore(config *Config) (Store, error) {
+	switch config.Server.Storage {
+	case "", "sqlite":
+		return OpenSQLiteStore(config.GetDatabasePath())
+	case "postgres":
+		return OpenPostgresStore(config.Server.DatabaseURL)
+	case "memory":
+		return NewMemoryStore(), nil
+	default:
+		return nil, errors.New("unknown storage backend " + config.Server.Storage)
+	}
+}
@@ -0,0 +1,1117 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore implements Store entirely in memory, for tests and throwaway dev instances.
+// Nothing survives a restart.
+type MemoryStore struct {
+	mu           sync.RWMutex
+	links        map[string]*URLMapping
+	users        map[string]*User
+	guilds       map[string]map[string]bool
+	sessions     map[string]memorySession
+	tokens       map[int]*memoryAPIToken
+	reports      map[int]*Report
+	transfers    map[string]*LinkTransfer
+	members      map[int]map[string]*LinkMember
+	audit        []AuditEvent
+	nextLinkID   int
+	nextTokenID  int
+	nextReportID int
+}
+
+// memorySession is a session held by MemoryStore
+type memorySession struct {
+	userID     string
+	createdAt  time.Time
+	lastSeenAt time.Time
+	expiresAt  time.Time
+	userAgent  string
+	ipAddress  string
+}
+
+// memoryAPIToken is a personal access token held by MemoryStore
+type memoryAPIToken struct {
+	APIToken
+	userID    string
+	tokenHash string
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		links:     make(map[string]*URLMapping),
+		users:     make(map[string]*User),
+		guilds:    make(map[string]map[string]bool),
+		sessions:  make(map[string]memorySession),
+		tokens:    make(map[int]*memoryAPIToken),
+		reports:   make(map[int]*Report),
+		transfers: make(map[string]*LinkTransfer),
+		members:   make(map[int]map[string]*LinkMember),
+	}
+}
+
+// Close is a no-op for the in-memory store
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+// copyLink returns a copy of a link so callers cannot mutate the store
+func copyLink(mapping *URLMapping) *URLMapping {
+	clone := *mapping
+	return &clone
+}
+
+// UpsertUser creates a user or refreshes their Discord profile
+func (m *MemoryStore) UpsertUser(ctx context.Context, user *User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	clone := *user
+	if existing, ok := m.users[user.ID]; ok {
+		clone.CreatedAt = existing.CreatedAt
+		clone.DisabledAt = existing.DisabledAt
+	} else {
+		clone.CreatedAt = dbTime(time.Now())
+	}
+	m.users[user.ID] = &clone
+	return nil
+}
+
+// CreateLink registers a new short code
+func (m *MemoryStore) CreateLink(ctx context.Context, shortCode, discordURL, ownerID string, expiry LinkExpiry, invite *DiscordInvite) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.links[shortCode]; exists {
+		return ErrCodeTaken
+	}
+
+	m.nextLinkID++
+	owner := ownerID
+	mapping := &URLMapping{
+		ID:         m.nextLinkID,
+		ShortCode:  shortCode,
+		DiscordURL: discordURL,
+		CreatedAt:  dbTime(time.Now()),
+		ExpiresAt:  expiry.dbExpiresAt(),
+		OwnerID:    &owner,
+		ExpiryDays: expiry.Days,
+	}
+	mapping.GuildID, mapping.GuildName = invite.guild()
+	mapping.InviteExpiresAt = invite.dbExpiresAt()
+	mapping.Health, mapping.HealthCheckedAt = invite.health()
+	mapping.VerifiedAt = invite.dbVerifiedAt()
+	m.links[shortCode] = mapping
+	return nil
+}
+
+// ResolveLink returns the Discord URL of an active, enabled link
+func (m *MemoryStore) ResolveLink(ctx context.Context, shortCode string) (string, error) {
+	mapping, err := m.GetLink(ctx, shortCode)
+	if err != nil {
+		return "", err
+	}
+	if mapping.DisabledAt != nil {
+		return "", ErrNotFound
+	}
+	return mapping.DiscordURL, nil
+}
+
+// GetLink returns an active link
+func (m *MemoryStore) GetLink(ctx context.Context, shortCode string) (*URLMapping, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	mapping, ok := m.links[shortCode]
+	if !ok || !isActive(mapping) {
+		return nil, ErrNotFound
+	}
+	return copyLink(mapping), nil
+}
+
+// GetLinkRecord returns a link whether or not it has expired
+func (m *MemoryStore) GetLinkRecord(ctx context.Context, shortCode string) (*URLMapping, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	mapping, ok := m.links[shortCode]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyLink(mapping), nil
+}
+
+// ListUserLinks returns every link a user owns or co-manages, newest first
+func (m *MemoryStore) ListUserLinks(ctx context.Context, userID string) ([]URLMapping, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var links []URLMapping
+	for _, mapping := range m.links {
+		if role := m.linkRole(mapping, userID); role != "" {
+			clone := *mapping
+			clone.Role = role
+			links = append(links, clone)
+		}
+	}
+
+	sort.Slice(links, func(i, j int) bool { return links[i].ID > links[j].ID })
+	return links, nil
+}
+
+// ownedLink returns a link for modification, checking it belongs to ownerID.
+// Callers must hold m.mu for writing.
+func (m *MemoryStore) ownedLink(shortCode, ownerID string) (*URLMapping, error) {
+	mapping, ok := m.links[shortCode]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if mapping.OwnerID == nil || *mapping.OwnerID != ownerID {
+		return nil, ErrNotOwner
+	}
+	return mapping, nil
+}
+
+// linkRole returns a user's role on a link, or "" if they have none. Callers must hold m.mu.
+func (m *MemoryStore) linkRole(mapping *URLMapping, userID string) string {
+	var role *string
+	if member, ok := m.members[mapping.ID][userID]; ok && member.AcceptedAt != nil {
+		role = &member.Role
+	}
+	return resolveRole(mapping.OwnerID, role, userID)
+}
+
+// managedLink returns a link for modification, checking userID has at least the required
+// role on it. Callers must hold m.mu for writing.
+func (m *MemoryStore) managedLink(shortCode, userID, required string) (*URLMapping, error) {
+	mapping, ok := m.links[shortCode]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if !hasRole(m.linkRole(mapping, userID), required) {
+		return nil, ErrNotOwner
+	}
+	return mapping, nil
+}
+
+// UpdateLinkURL changes the Discord destination of a link userID may edit
+func (m *MemoryStore) UpdateLinkURL(ctx context.Context, shortCode, userID, discordURL string, invite *DiscordInvite) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mapping, err := m.managedLink(shortCode, userID, RoleEditor)
+	if err != nil {
+		return err
+	}
+	if mapping.DisabledAt != nil {
+		return ErrLinkDisabled
+	}
+
+	now := dbTime(time.Now())
+	mapping.DiscordURL = discordURL
+	mapping.UpdatedAt = &now
+	mapping.GuildID, mapping.GuildName = invite.guild()
+	mapping.InviteExpiresAt = invite.dbExpiresAt()
+	mapping.Health, mapping.HealthCheckedAt = invite.health()
+	mapping.VerifiedAt = invite.dbVerifiedAt()
+	return nil
+}
+
+// SetLinkExpiry changes the expiry of a link userID may edit, unless it is disabled
+func (m *MemoryStore) SetLinkExpiry(ctx context.Context, shortCode, userID string, expiry LinkExpiry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mapping, err := m.managedLink(shortCode, userID, RoleEditor)
+	if err != nil {
+		return err
+	}
+	if mapping.DisabledAt != nil {
+		return ErrLinkDisabled
+	}
+
+	mapping.ExpiresAt = expiry.dbExpiresAt()
+	mapping.ExpiryDays = expiry.Days
+	return nil
+}
+
+// DeleteLink removes a link userID owns or co-owns
+func (m *MemoryStore) DeleteLink(ctx context.Context, shortCode, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mapping, err := m.managedLink(shortCode, userID, RoleOwner)
+	if err != nil {
+		return err
+	}
+	// Disabled links are kept so their code can't be registered again
+	if mapping.DisabledAt != nil {
+		return ErrLinkDisabled
+	}
+
+	delete(m.members, mapping.ID)
+	delete(m.links, shortCode)
+	return nil
+}
+
+// PurgeExpiredLinks removes links that expired before the cutoff, keeping disabled ones so
+// their code can't be registered again
+func (m *MemoryStore) PurgeExpiredLinks(ctx context.Context, cutoff time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var removed int64
+	for shortCode, mapping := range m.links {
+		if mapping.ExpiresAt == nil || mapping.DisabledAt != nil {
+			continue
+		}
+		if expiresAt, err := parseTimestamp(*mapping.ExpiresAt); err == nil && !expiresAt.After(cutoff) {
+			delete(m.members, mapping.ID)
+			delete(m.links, shortCode)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// ListLinksToCheck returns active links due an invite health check, never-checked ones first
+func (m *MemoryStore) ListLinksToCheck(ctx context.Context, checkedBefore time.Time, limit int) ([]URLMapping, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var links []URLMapping
+	for _, mapping := range m.links {
+		if !isActive(mapping) {
+			continue
+		}
+		if mapping.HealthCheckedAt != nil {
+			checkedAt, err := parseTimestamp(*mapping.HealthCheckedAt)
+			if err == nil && checkedAt.After(checkedBefore) {
+				continue
+			}
+		}
+		links = append(links, *mapping)
+	}
+
+	// dbTime strings sort chronologically
+	sort.Slice(links, func(i, j int) bool {
+		a, b := links[i].HealthCheckedAt, links[j].HealthCheckedAt
+		if (a == nil) != (b == nil) {
+			return a == nil
+		}
+		if a != nil && *a != *b {
+			return *a < *b
+		}
+		return links[i].ID < links[j].ID
+	})
+	if len(links) > limit {
+		links = links[:limit]
+	}
+	return links, nil
+}
+
+// SetLinkHealth records the result of an invite health check
+func (m *MemoryStore) SetLinkHealth(ctx context.Context, shortCode, health string, checkedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mapping, ok := m.links[shortCode]
+	if !ok {
+		return ErrNotFound
+	}
+
+	checked := dbTime(checkedAt)
+	mapping.Health = &health
+	mapping.HealthCheckedAt = &checked
+	return nil
+}
+
+// CreateAPIToken stores the digest of a new personal access token
+func (m *MemoryStore) CreateAPIToken(ctx context.Context, userID, name, tokenHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextTokenID++
+	m.tokens[m.nextTokenID] = &memoryAPIToken{
+		APIToken:  APIToken{ID: m.nextTokenID, Name: name, CreatedAt: dbTime(time.Now())},
+		userID:    userID,
+		tokenHash: tokenHash,
+	}
+	return nil
+}
+
+// ListAPITokens returns a user's personal access tokens, newest first
+func (m *MemoryStore) ListAPITokens(ctx context.Context, userID string) ([]APIToken, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var tokens []APIToken
+	for _, token := range m.tokens {
+		if token.userID == userID {
+			tokens = append(tokens, token.APIToken)
+		}
+	}
+
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID > tokens[j].ID })
+	return tokens, nil
+}
+
+// GetUserByAPIToken returns the owner of a token digest and records that it was used
+func (m *MemoryStore) GetUserByAPIToken(ctx context.Context, tokenHash string) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, token := range m.tokens {
+		if token.tokenHash != tokenHash {
+			continue
+		}
+
+		user, ok := m.users[token.userID]
+		if !ok || user.DisabledAt != nil {
+			return nil, ErrNotFound
+		}
+
+		now := dbTime(time.Now())
+		token.LastUsedAt = &now
+		clone := *user
+		return &clone, nil
+	}
+	return nil, ErrNotFound
+}
+
+// DeleteAPIToken revokes a personal access token owned by userID
+func (m *MemoryStore) DeleteAPIToken(ctx context.Context, tokenID int, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.tokens[tokenID]
+	if !ok || token.userID != userID {
+		return ErrNotFound
+	}
+
+	delete(m.tokens, tokenID)
+	return nil
+}
+
+// SetManagedGuilds replaces the guilds a user can manage
+func (m *MemoryStore) SetManagedGuilds(ctx context.Context, userID string, guildIDs []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	guilds := make(map[string]bool, len(guildIDs))
+	for _, guildID := range guildIDs {
+		guilds[guildID] = true
+	}
+	m.guilds[userID] = guilds
+	return nil
+}
+
+// ManagesGuild reports whether a guild was among those a user could manage at login
+func (m *MemoryStore) ManagesGuild(ctx context.Context, userID, guildID string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.guilds[userID][guildID], nil
+}
+
+// CreateSession stores a new session for a user
+func (m *MemoryStore) CreateSession(ctx context.Context, tokenHash, userID string, expiresAt time.Time, userAgent, ipAddress string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.sessions[tokenHash] = memorySession{
+		userID:     userID,
+		createdAt:  now,
+		lastSeenAt: now,
+		expiresAt:  expiresAt,
+		userAgent:  userAgent,
+		ipAddress:  ipAddress,
+	}
+	return nil
+}
+
+// active reports whether a session is unexpired and was last seen after idleSince
+func (session memorySession) active(idleSince time.Time) bool {
+	return session.expiresAt.After(time.Now()) && session.lastSeenAt.After(idleSince)
+}
+
+// GetSessionUser returns the user of an active session and records that it was used
+func (m *MemoryStore) GetSessionUser(ctx context.Context, tokenHash string, idleSince time.Time) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[tokenHash]
+	if !ok || !session.active(idleSince) {
+		return nil, ErrNotFound
+	}
+
+	user, ok := m.users[session.userID]
+	if !ok || user.DisabledAt != nil {
+		return nil, ErrNotFound
+	}
+
+	session.lastSeenAt = time.Now()
+	m.sessions[tokenHash] = session
+
+	clone := *user
+	return &clone, nil
+}
+
+// ListUserSessions returns a user's active sessions, most recently used first
+func (m *MemoryStore) ListUserSessions(ctx context.Context, userID string, idleSince time.Time) ([]Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var ids []string
+	for tokenHash, session := range m.sessions {
+		if session.userID == userID && session.active(idleSince) {
+			ids = append(ids, tokenHash)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return m.sessions[ids[i]].lastSeenAt.After(m.sessions[ids[j]].lastSeenAt)
+	})
+
+	sessions := make([]Session, len(ids))
+	for i, tokenHash := range ids {
+		session := m.sessions[tokenHash]
+		sessions[i] = Session{
+			ID:         tokenHash,
+			UserID:     session.userID,
+			CreatedAt:  dbTime(session.createdAt),
+			LastSeenAt: dbTime(session.lastSeenAt),
+			ExpiresAt:  dbTime(session.expiresAt),
+			UserAgent:  session.userAgent,
+			IPAddress:  session.ipAddress,
+		}
+	}
+	return sessions, nil
+}
+
+// DeleteSession removes a session
+func (m *MemoryStore) DeleteSession(ctx context.Context, tokenHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, tokenHash)
+	return nil
+}
+
+// DeleteOtherSessions removes every session of a user except keepTokenHash
+func (m *MemoryStore) DeleteOtherSessions(ctx context.Context, userID, keepTokenHash string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var removed int64
+	for tokenHash, session := range m.sessions {
+		if session.userID == userID && tokenHash != keepTokenHash {
+			delete(m.sessions, tokenHash)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// PurgeExpiredSessions removes every expired or idle session
+func (m *MemoryStore) PurgeExpiredSessions(ctx context.Context, idleSince time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var removed int64
+	for tokenHash, session := range m.sessions {
+		if !session.active(idleSince) {
+			delete(m.sessions, tokenHash)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// containsFold reports whether value contains search, ignoring case
+func containsFold(value *string, search string) bool {
+	return value != nil && strings.Contains(strings.ToLower(*value), strings.ToLower(search))
+}
+
+// adminLess returns the comparator for query.Sort, reversed when descending, or fallback
+// (newest first) for unknown sort keys
+func adminLess(query AdminQuery, sorts map[string]func(i, j int) bool, fallback func(i, j int) bool) func(i, j int) bool {
+	less, ok := sorts[query.Sort]
+	if !ok {
+		return fallback
+	}
+	if query.Desc {
+		return func(i, j int) bool { return less(j, i) }
+	}
+	return less
+}
+
+// limitListing returns how many of n results fit within a query's limit
+func limitListing(n int, query AdminQuery) int {
+	if query.Limit < n {
+		return query.Limit
+	}
+	return n
+}
+
+// ListAllLinks returns links from every owner matching the query
+func (m *MemoryStore) ListAllLinks(ctx context.Context, query AdminQuery) ([]AdminLink, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var links []AdminLink
+	for _, mapping := range m.links {
+		link := AdminLink{URLMapping: *mapping}
+		if mapping.OwnerID != nil {
+			if owner, ok := m.users[*mapping.OwnerID]; ok {
+				username := owner.Username
+				link.OwnerUsername = &username
+			}
+		}
+
+		if query.Search != "" {
+			matches := containsFold(&link.ShortCode, query.Search) || containsFold(&link.DiscordURL, query.Search) ||
+				containsFold(link.GuildName, query.Search) || containsFold(link.OwnerUsername, query.Search) ||
+				(link.OwnerID != nil && *link.OwnerID == query.Search)
+			if !matches {
+				continue
+			}
+		}
+		links = append(links, link)
+	}
+
+	deref := func(value *string) string {
+		if value == nil {
+			return ""
+		}
+		return *value
+	}
+	sort.Slice(links, adminLess(query, map[string]func(i, j int) bool{
+		"code":    func(i, j int) bool { return links[i].ShortCode < links[j].ShortCode },
+		"created": func(i, j int) bool { return links[i].ID < links[j].ID },
+		"expires": func(i, j int) bool { return deref(links[i].ExpiresAt) < deref(links[j].ExpiresAt) },
+		"owner":   func(i, j int) bool { return deref(links[i].OwnerUsername) < deref(links[j].OwnerUsername) },
+		"health":  func(i, j int) bool { return deref(links[i].Health) < deref(links[j].Health) },
+	}, func(i, j int) bool { return links[i].ID > links[j].ID }))
+	return links[:limitListing(len(links), query)], nil
+}
+
+// ListUsers returns users matching the query with how many links each owns
+func (m *MemoryStore) ListUsers(ctx context.Context, query AdminQuery) ([]UserSummary, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	linkCounts := make(map[string]int)
+	for _, mapping := range m.links {
+		if mapping.OwnerID != nil {
+			linkCounts[*mapping.OwnerID]++
+		}
+	}
+
+	var users []UserSummary
+	for _, user := range m.users {
+		if query.Search != "" && !containsFold(&user.Username, query.Search) && !containsFold(&user.GlobalName, query.Search) && user.ID != query.Search {
+			continue
+		}
+		users = append(users, UserSummary{User: *user, LinkCount: linkCounts[user.ID]})
+	}
+
+	sort.Slice(users, adminLess(query, map[string]func(i, j int) bool{
+		"username": func(i, j int) bool { return users[i].Username < users[j].Username },
+		"created":  func(i, j int) bool { return users[i].CreatedAt < users[j].CreatedAt },
+		"links":    func(i, j int) bool { return users[i].LinkCount < users[j].LinkCount },
+	}, func(i, j int) bool { return users[i].CreatedAt > users[j].CreatedAt }))
+	return users[:limitListing(len(users), query)], nil
+}
+
+// GetUser returns a user whether or not they are disabled
+func (m *MemoryStore) GetUser(ctx context.Context, userID string) (*User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	clone := *user
+	return &clone, nil
+}
+
+// AdminDeleteLink removes a link whoever owns it
+func (m *MemoryStore) AdminDeleteLink(ctx context.Context, shortCode string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mapping, ok := m.links[shortCode]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(m.members, mapping.ID)
+	delete(m.links, shortCode)
+	return nil
+}
+
+// ReassignLink moves a link to another existing user
+func (m *MemoryStore) ReassignLink(ctx context.Context, shortCode, newOwnerID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mapping, ok := m.links[shortCode]
+	if _, exists := m.users[newOwnerID]; !ok || !exists {
+		return ErrNotFound
+	}
+
+	owner := newOwnerID
+	mapping.OwnerID = &owner
+	return nil
+}
+
+// SetUserDisabled disables or re-enables a user, ending their sessions when disabling
+func (m *MemoryStore) SetUserDisabled(ctx context.Context, userID string, disabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+
+	user.DisabledAt = memoryDisabledAt(disabled)
+	if !disabled {
+		return nil
+	}
+
+	for sessionID, session := range m.sessions {
+		if session.userID == userID {
+			delete(m.sessions, sessionID)
+		}
+	}
+	return nil
+}
+
+// memoryDisabledAt returns the DisabledAt value for a record being disabled or re-enabled
+func memoryDisabledAt(disabled bool) *string {
+	if !disabled {
+		return nil
+	}
+	now := dbTime(time.Now())
+	return &now
+}
+
+// SetLinkDisabled disables or re-enables any link
+func (m *MemoryStore) SetLinkDisabled(ctx context.Context, shortCode string, disabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mapping, ok := m.links[shortCode]
+	if !ok {
+		return ErrNotFound
+	}
+	mapping.DisabledAt = memoryDisabledAt(disabled)
+	return nil
+}
+
+// DisableUserLinks disables every link a user owns that isn't already disabled
+func (m *MemoryStore) DisableUserLinks(ctx context.Context, userID string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var disabled int64
+	for _, mapping := range m.links {
+		if mapping.OwnerID != nil && *mapping.OwnerID == userID && mapping.DisabledAt == nil {
+			mapping.DisabledAt = memoryDisabledAt(true)
+			disabled++
+		}
+	}
+	return disabled, nil
+}
+
+// CreateReport files a new open report
+func (m *MemoryStore) CreateReport(ctx context.Context, report *Report) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextReportID++
+	clone := *report
+	clone.ID = m.nextReportID
+	clone.Status = ReportOpen
+	clone.CreatedAt = dbTime(time.Now())
+	m.reports[clone.ID] = &clone
+	return nil
+}
+
+// ListReports returns reports with the given status, oldest first
+func (m *MemoryStore) ListReports(ctx context.Context, status string, limit int) ([]Report, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var reports []Report
+	for _, report := range m.reports {
+		if report.Status == status {
+			reports = append(reports, *report)
+		}
+	}
+
+	sort.Slice(reports, func(i, j int) bool { return reports[i].ID < reports[j].ID })
+	if len(reports) > limit {
+		reports = reports[:limit]
+	}
+	return reports, nil
+}
+
+// GetReport returns a single report
+func (m *MemoryStore) GetReport(ctx context.Context, reportID int) (*Report, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	report, ok := m.reports[reportID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	clone := *report
+	return &clone, nil
+}
+
+// resolveMemoryReport closes an open report. Callers must hold m.mu for writing.
+func resolveMemoryReport(report *Report, status, resolvedBy string) {
+	now := dbTime(time.Now())
+	report.Status = status
+	report.ResolvedAt = &now
+	report.ResolvedBy = &resolvedBy
+}
+
+// ResolveReport closes one open report with the given status
+func (m *MemoryStore) ResolveReport(ctx context.Context, reportID int, status, resolvedBy string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	report, ok := m.reports[reportID]
+	if !ok || report.Status != ReportOpen {
+		return ErrNotFound
+	}
+	resolveMemoryReport(report, status, resolvedBy)
+	return nil
+}
+
+// ResolveLinkReports closes every open report against a short code
+func (m *MemoryStore) ResolveLinkReports(ctx context.Context, shortCode, status, resolvedBy string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var resolved int64
+	for _, report := range m.reports {
+		if report.ShortCode == shortCode && report.Status == ReportOpen {
+			resolveMemoryReport(report, status, resolvedBy)
+			resolved++
+		}
+	}
+	return resolved, nil
+}
+
+// RecordAuditEvent appends an event to the audit log
+func (m *MemoryStore) RecordAuditEvent(ctx context.Context, event *AuditEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.appendAuditEvent(event)
+	return nil
+}
+
+// appendAuditEvent adds an event to the audit log. Callers must hold m.mu for writing.
+func (m *MemoryStore) appendAuditEvent(event *AuditEvent) {
+	clone := *event
+	clone.ID = len(m.audit) + 1
+	clone.CreatedAt = dbTime(time.Now())
+	m.audit = append(m.audit, clone)
+}
+
+// matchesAuditQuery reports whether an event passes the query's filters
+func matchesAuditQuery(event AuditEvent, query AuditQuery) bool {
+	if query.UserID != "" &&
+		(event.ActorID == nil || *event.ActorID != query.UserID) &&
+		(event.SubjectID == nil || *event.SubjectID != query.UserID) {
+		return false
+	}
+	if query.Action != "" && event.Action != query.Action {
+		return false
+	}
+	if query.ShortCode != "" && (event.ShortCode == nil || *event.ShortCode != query.ShortCode) {
+		return false
+	}
+	if !query.Since.IsZero() && event.CreatedAt < dbTime(query.Since) {
+		return false
+	}
+	if !query.Until.IsZero() && event.CreatedAt >= dbTime(query.Until) {
+		return false
+	}
+	return true
+}
+
+// ListAuditEvents returns events matching the query, newest first
+func (m *MemoryStore) ListAuditEvents(ctx context.Context, query AuditQuery) ([]AuditEvent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var events []AuditEvent
+	for i := len(m.audit) - 1; i >= 0 && len(events) < query.Limit; i-- {
+		if matchesAuditQuery(m.audit[i], query) {
+			events = append(events, m.audit[i])
+		}
+	}
+	return events, nil
+}
+
+// OfferTransfer offers a link owned by fromUserID to toUserID, replacing any pending offer.
+// Disabled links can't be offered.
+func (m *MemoryStore) OfferTransfer(ctx context.Context, shortCode, fromUserID, toUserID string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mapping, err := m.ownedLink(shortCode, fromUserID)
+	if err != nil {
+		return err
+	}
+	if mapping.DisabledAt != nil {
+		return ErrLinkDisabled
+	}
+
+	m.transfers[shortCode] = &LinkTransfer{
+		ShortCode:  shortCode,
+		FromUserID: fromUserID,
+		ToUserID:   toUserID,
+		CreatedAt:  dbTime(time.Now()),
+		ExpiresAt:  dbTime(expiresAt),
+	}
+	return nil
+}
+
+// pendingTransfer returns an offer with its link details filled in, or nil if it has expired or
+// the link no longer belongs to the user who made it. Callers must hold m.mu.
+func (m *MemoryStore) pendingTransfer(transfer *LinkTransfer) *LinkTransfer {
+	if transfer.ExpiresAt <= dbTime(time.Now()) {
+		return nil
+	}
+	mapping, ok := m.links[transfer.ShortCode]
+	if !ok || mapping.OwnerID == nil || *mapping.OwnerID != transfer.FromUserID {
+		return nil
+	}
+
+	clone := *transfer
+	clone.DiscordURL = mapping.DiscordURL
+	if user, ok := m.users[transfer.FromUserID]; ok {
+		clone.FromUsername = user.Username
+	}
+	return &clone
+}
+
+// listTransfers returns pending offers accepted by match, newest first
+func (m *MemoryStore) listTransfers(match func(transfer *LinkTransfer) bool) []LinkTransfer {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var transfers []LinkTransfer
+	for _, transfer := range m.transfers {
+		if pending := m.pendingTransfer(transfer); pending != nil && match(pending) {
+			transfers = append(transfers, *pending)
+		}
+	}
+
+	sort.Slice(transfers, func(i, j int) bool { return transfers[i].CreatedAt > transfers[j].CreatedAt })
+	return transfers
+}
+
+// ListIncomingTransfers returns pending offers made to a user
+func (m *MemoryStore) ListIncomingTransfers(ctx context.Context, userID string) ([]LinkTransfer, error) {
+	return m.listTransfers(func(transfer *LinkTransfer) bool { return transfer.ToUserID == userID }), nil
+}
+
+// ListOutgoingTransfers returns pending offers a user has made
+func (m *MemoryStore) ListOutgoingTransfers(ctx context.Context, userID string) ([]LinkTransfer, error) {
+	return m.listTransfers(func(transfer *LinkTransfer) bool { return transfer.FromUserID == userID }), nil
+}
+
+// takeTransfer removes and returns a pending offer accepted by match. Callers must hold m.mu
+// for writing.
+func (m *MemoryStore) takeTransfer(shortCode string, match func(transfer *LinkTransfer) bool) (*LinkTransfer, error) {
+	transfer, ok := m.transfers[shortCode]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	pending := m.pendingTransfer(transfer)
+	if pending == nil || !match(pending) {
+		return nil, ErrNotFound
+	}
+
+	delete(m.transfers, shortCode)
+	return pending, nil
+}
+
+// CancelTransfer withdraws an offer made by fromUserID
+func (m *MemoryStore) CancelTransfer(ctx context.Context, shortCode, fromUserID string) (*LinkTransfer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.takeTransfer(shortCode, func(transfer *LinkTransfer) bool { return transfer.FromUserID == fromUserID })
+}
+
+// DeclineTransfer refuses an offer made to toUserID
+func (m *MemoryStore) DeclineTransfer(ctx context.Context, shortCode, toUserID string) (*LinkTransfer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.takeTransfer(shortCode, func(transfer *LinkTransfer) bool { return transfer.ToUserID == toUserID })
+}
+
+// AcceptTransfer moves the link to toUserID and records it in the audit log, all at once
+func (m *MemoryStore) AcceptTransfer(ctx context.Context, shortCode, toUserID string) (*LinkTransfer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	transfer, err := m.takeTransfer(shortCode, func(transfer *LinkTransfer) bool { return transfer.ToUserID == toUserID })
+	if err != nil {
+		return nil, err
+	}
+
+	owner := toUserID
+	m.links[shortCode].OwnerID = &owner
+
+	event := newAuditEvent(toUserID, AuditTransferAccept, shortCode)
+	event.SubjectID = &transfer.FromUserID
+	m.appendAuditEvent(event)
+	return transfer, nil
+}
+
+// LinkRole returns a user's role on a link, or ErrNotOwner if they have none
+func (m *MemoryStore) LinkRole(ctx context.Context, shortCode, userID string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	mapping, ok := m.links[shortCode]
+	if !ok {
+		return "", ErrNotFound
+	}
+	if role := m.linkRole(mapping, userID); role != "" {
+		return role, nil
+	}
+	return "", ErrNotOwner
+}
+
+// InviteMember invites userID to a link inviterID owns or co-owns, unless it is disabled
+func (m *MemoryStore) InviteMember(ctx context.Context, shortCode, inviterID, userID, role string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mapping, err := m.managedLink(shortCode, inviterID, RoleOwner)
+	if err != nil {
+		return err
+	}
+	if mapping.DisabledAt != nil {
+		return ErrLinkDisabled
+	}
+
+	if existing, ok := m.members[mapping.ID][userID]; ok {
+		// A different role only takes effect once they accept it
+		if existing.Role != role {
+			existing.AcceptedAt = nil
+		}
+		existing.Role = role
+		existing.InvitedBy = inviterID
+		return nil
+	}
+	if m.members[mapping.ID] == nil {
+		m.members[mapping.ID] = make(map[string]*LinkMember)
+	}
+	m.members[mapping.ID][userID] = &LinkMember{
+		UserID:    userID,
+		Role:      role,
+		InvitedBy: inviterID,
+		CreatedAt: dbTime(time.Now()),
+	}
+	return nil
+}
+
+// listMembers returns members accepted by match, oldest first, leaving out whoever now owns
+// the link
+func (m *MemoryStore) listMembers(match func(mapping *URLMapping, member *LinkMember) bool) []LinkMember {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var members []LinkMember
+	for _, mapping := range m.links {
+		for _, member := range m.members[mapping.ID] {
+			if mapping.OwnerID != nil && *mapping.OwnerID == member.UserID || !match(mapping, member) {
+				continue
+			}
+			clone := *member
+			clone.ShortCode = mapping.ShortCode
+			clone.DiscordURL = mapping.DiscordURL
+			if user, ok := m.users[member.UserID]; ok {
+				clone.Username = user.Username
+			}
+			members = append(members, clone)
+		}
+	}
+
+	sort.Slice(members, func(i, j int) bool { return members[i].CreatedAt < members[j].CreatedAt })
+	return members
+}
+
+// ListLinkMembers returns a link's members and pending invitations
+func (m *MemoryStore) ListLinkMembers(ctx context.Context, shortCode string) ([]LinkMember, error) {
+	return m.listMembers(func(mapping *URLMapping, member *LinkMember) bool {
+		return mapping.ShortCode == shortCode
+	}), nil
+}
+
+// ListMemberInvites returns the invitations a user has not yet accepted
+func (m *MemoryStore) ListMemberInvites(ctx context.Context, userID string) ([]LinkMember, error) {
+	return m.listMembers(func(mapping *URLMapping, member *LinkMember) bool {
+		return member.UserID == userID && member.AcceptedAt == nil
+	}), nil
+}
+
+// AcceptMemberInvite makes a pending invitation to userID take effect
+func (m *MemoryStore) AcceptMemberInvite(ctx context.Context, shortCode, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mapping, ok := m.links[shortCode]
+	if !ok {
+		return ErrNotFound
+	}
+	member, ok := m.members[mapping.ID][userID]
+	if !ok || member.AcceptedAt != nil {
+		return ErrNotFound
+	}
+
+	now := dbTime(time.Now())
+	member.AcceptedAt = &now
+	return nil
+}
+
+// RemoveMember removes a member or invitation, by an owner or the member themselves
+func (m *MemoryStore) RemoveMember(ctx context.Context, shortCode, removerID, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mapping, ok := m.links[shortCode]
+	if !ok {
+		return ErrNotFound
+	}
+	if removerID != userID && !hasRole(m.linkRole(mapping, removerID), RoleOwner) {
+		return ErrNotOwner
+	}
+	if _, ok := m.members[mapping.ID][userID]; !ok {
+		return ErrNotFound
+	}
+
+	delete(m.members[mapping.ID], userID)
+	return nil
+}
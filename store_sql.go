@@ -0,0 +1,1094 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sqlDialect describes what differs between the SQL databases SQLStore supports
+type sqlDialect struct {
+	// name is the storage setting that selects the dialect
+	name string
+	// driver is the database/sql driver name
+	driver string
+	// migrationsDir is the directory of embedded migrations for this database
+	migrationsDir string
+	// numberedParams is set when the driver expects $1, $2, ... instead of ? placeholders
+	numberedParams bool
+	// migrationsTable creates the schema_migrations table
+	migrationsTable string
+	// isUniqueViolation reports whether an error is a unique constraint failure
+	isUniqueViolation func(err error) bool
+	// legacyMarkers detect migrations already reflected in databases that predate schema_migrations
+	legacyMarkers map[int]func(st *SQLStore) (bool, error)
+}
+
+// SQLStore implements Store on a SQL database
+type SQLStore struct {
+	db      *sql.DB
+	dialect *sqlDialect
+}
+
+// openSQLStore opens a database connection for the given dialect
+func openSQLStore(dialect *sqlDialect, dsn string) (*SQLStore, error) {
+	db, err := sql.Open(dialect.driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return &SQLStore{db: db, dialect: dialect}, nil
+}
+
+// Close closes the database connection
+func (st *SQLStore) Close() error {
+	return st.db.Close()
+}
+
+// rebind rewrites ? placeholders into the form the dialect expects
+func (st *SQLStore) rebind(query string) string {
+	if !st.dialect.numberedParams {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// exec runs a statement written with ? placeholders
+func (st *SQLStore) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return st.db.ExecContext(ctx, st.rebind(query), args...)
+}
+
+// query runs a query written with ? placeholders
+func (st *SQLStore) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return st.db.QueryContext(ctx, st.rebind(query), args...)
+}
+
+// queryRow runs a single-row query written with ? placeholders
+func (st *SQLStore) queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return st.db.QueryRowContext(ctx, st.rebind(query), args...)
+}
+
+// notFound maps sql.ErrNoRows to ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// UpsertUser creates or updates a user in the database
+func (st *SQLStore) UpsertUser(ctx context.Context, user *User) error {
+	_, err := st.exec(ctx, `
+		INSERT INTO users (id, username, global_name, avatar, discriminator)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			username = excluded.username,
+			global_name = excluded.global_name,
+			avatar = excluded.avatar,
+			discriminator = excluded.discriminator
+	`, user.ID, user.Username, user.GlobalName, user.Avatar, user.Discriminator)
+
+	return err
+}
+
+// ListUserLinks retrieves all URL mappings a user owns or co-manages, including expired
+// ones so they can still be renewed
+func (st *SQLStore) ListUserLinks(ctx context.Context, userID string) ([]URLMapping, error) {
+	rows, err := st.query(ctx, `
+		SELECT m.short_code, m.discord_url, m.created_at, m.updated_at, m.expires_at, m.owner_id, m.expiry_days, m.guild_id, m.guild_name, m.invite_expires_at, m.health, m.health_checked_at, m.disabled_at, m.verified_at, lm.role
+		FROM url_mappings m
+		LEFT JOIN link_members lm ON lm.link_id = m.id AND lm.user_id = ? AND lm.accepted_at IS NOT NULL
+		WHERE m.owner_id = ? OR lm.user_id IS NOT NULL
+		ORDER BY m.created_at DESC
+	`, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []URLMapping
+	for rows.Next() {
+		var mapping URLMapping
+		var memberRole *string
+		err := rows.Scan(&mapping.ShortCode, &mapping.DiscordURL, &mapping.CreatedAt, &mapping.UpdatedAt, &mapping.ExpiresAt, &mapping.OwnerID, &mapping.ExpiryDays, &mapping.GuildID, &mapping.GuildName, &mapping.InviteExpiresAt, &mapping.Health, &mapping.HealthCheckedAt, &mapping.DisabledAt, &mapping.VerifiedAt, &memberRole)
+		if err != nil {
+			return nil, err
+		}
+		mapping.Role = resolveRole(mapping.OwnerID, memberRole, userID)
+		links = append(links, mapping)
+	}
+
+	return links, rows.Err()
+}
+
+// CreateLink creates a new URL mapping in the database
+func (st *SQLStore) CreateLink(ctx context.Context, shortCode, discordURL, ownerID string, expiry LinkExpiry, invite *DiscordInvite) error {
+	guildID, guildName := invite.guild()
+	health, checkedAt := invite.health()
+	_, err := st.exec(ctx, `
+		INSERT INTO url_mappings (short_code, discord_url, owner_id, expires_at, expiry_days, guild_id, guild_name, invite_expires_at, health, health_checked_at, verified_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, shortCode, discordURL, ownerID, expiry.dbExpiresAt(), expiry.Days, guildID, guildName, invite.dbExpiresAt(), health, checkedAt, invite.dbVerifiedAt())
+	if err != nil && st.dialect.isUniqueViolation(err) {
+		return ErrCodeTaken
+	}
+	return err
+}
+
+// ResolveLink retrieves the Discord URL of an active mapping by its short code
+func (st *SQLStore) ResolveLink(ctx context.Context, shortCode string) (string, error) {
+	var discordURL string
+	err := st.queryRow(ctx,
+		"SELECT discord_url FROM url_mappings WHERE short_code = ? AND (expires_at IS NULL OR expires_at > ?) AND disabled_at IS NULL",
+		shortCode, dbTime(time.Now()),
+	).Scan(&discordURL)
+	return discordURL, notFound(err)
+}
+
+// GetLink retrieves the full URL mapping record for an active short code
+func (st *SQLStore) GetLink(ctx context.Context, shortCode string) (*URLMapping, error) {
+	return st.getLink(ctx, shortCode, true)
+}
+
+// GetLinkRecord retrieves a URL mapping by its short code whether or not it has expired
+func (st *SQLStore) GetLinkRecord(ctx context.Context, shortCode string) (*URLMapping, error) {
+	return st.getLink(ctx, shortCode, false)
+}
+
+// getLink retrieves a URL mapping, optionally ignoring expired ones
+func (st *SQLStore) getLink(ctx context.Context, shortCode string, activeOnly bool) (*URLMapping, error) {
+	query := `
+		SELECT id, short_code, discord_url, created_at, expires_at, owner_id, updated_at, expiry_days, guild_id, guild_name, invite_expires_at, health, health_checked_at, disabled_at, verified_at
+		FROM url_mappings
+		WHERE short_code = ?`
+	args := []interface{}{shortCode}
+	if activeOnly {
+		query += " AND (expires_at IS NULL OR expires_at > ?)"
+		args = append(args, dbTime(time.Now()))
+	}
+
+	var mapping URLMapping
+	err := st.queryRow(ctx, query, args...).Scan(&mapping.ID, &mapping.ShortCode, &mapping.DiscordURL, &mapping.CreatedAt, &mapping.ExpiresAt, &mapping.OwnerID, &mapping.UpdatedAt, &mapping.ExpiryDays, &mapping.GuildID, &mapping.GuildName, &mapping.InviteExpiresAt, &mapping.Health, &mapping.HealthCheckedAt, &mapping.DisabledAt, &mapping.VerifiedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &mapping, nil
+}
+
+// ownerMismatch explains why a change to a user's link affected no rows
+func (st *SQLStore) ownerMismatch(ctx context.Context, shortCode string) error {
+	var ownerID string
+	err := st.queryRow(ctx,
+		"SELECT owner_id FROM url_mappings WHERE short_code = ?",
+		shortCode,
+	).Scan(&ownerID)
+	if err != nil {
+		return notFound(err)
+	}
+	return ErrNotOwner
+}
+
+// execOwned runs a statement against a user's link, mapping "no rows changed" to a typed error
+func (st *SQLStore) execOwned(ctx context.Context, shortCode, query string, args ...interface{}) error {
+	result, err := st.exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return st.ownerMismatch(ctx, shortCode)
+	}
+	return nil
+}
+
+// Conditions restricting a url_mappings statement to links a user may edit, or may delete and
+// manage members of. Each takes the user's ID twice.
+const (
+	editableBy = `(owner_id = ? OR id IN (SELECT link_id FROM link_members WHERE user_id = ? AND accepted_at IS NOT NULL))`
+	ownedBy    = `(owner_id = ? OR id IN (SELECT link_id FROM link_members WHERE user_id = ? AND accepted_at IS NOT NULL AND role = 'owner'))`
+)
+
+// disabledMismatch turns ErrNotOwner from execOwned into ErrLinkDisabled when the link meets
+// condition with args, so the change only failed because a moderator disabled the link
+func (st *SQLStore) disabledMismatch(ctx context.Context, err error, shortCode, condition string, args ...interface{}) error {
+	if !errors.Is(err, ErrNotOwner) {
+		return err
+	}
+
+	var count int
+	if st.queryRow(ctx,
+		"SELECT COUNT(*) FROM url_mappings WHERE short_code = ? AND disabled_at IS NOT NULL AND "+condition,
+		append([]interface{}{shortCode}, args...)...,
+	).Scan(&count) == nil && count > 0 {
+		return ErrLinkDisabled
+	}
+	return err
+}
+
+// UpdateLinkURL changes the Discord URL of a mapping the user may edit, unless it is disabled
+func (st *SQLStore) UpdateLinkURL(ctx context.Context, shortCode, userID, discordURL string, invite *DiscordInvite) error {
+	guildID, guildName := invite.guild()
+	health, checkedAt := invite.health()
+	err := st.execOwned(ctx, shortCode, `
+		UPDATE url_mappings
+		SET discord_url = ?, updated_at = ?, guild_id = ?, guild_name = ?, invite_expires_at = ?, health = ?, health_checked_at = ?, verified_at = ?
+		WHERE short_code = ? AND disabled_at IS NULL AND `+editableBy,
+		discordURL, dbTime(time.Now()), guildID, guildName, invite.dbExpiresAt(), health, checkedAt, invite.dbVerifiedAt(), shortCode, userID, userID)
+	return st.disabledMismatch(ctx, err, shortCode, editableBy, userID, userID)
+}
+
+// SetLinkExpiry changes the expiry of a mapping the user may edit, unless it is disabled
+func (st *SQLStore) SetLinkExpiry(ctx context.Context, shortCode, userID string, expiry LinkExpiry) error {
+	err := st.execOwned(ctx, shortCode,
+		"UPDATE url_mappings SET expires_at = ?, expiry_days = ? WHERE short_code = ? AND disabled_at IS NULL AND "+editableBy,
+		expiry.dbExpiresAt(), expiry.Days, shortCode, userID, userID,
+	)
+	return st.disabledMismatch(ctx, err, shortCode, editableBy, userID, userID)
+}
+
+// DeleteLink deletes a URL mapping the user owns or co-owns. Disabled links are kept so
+// their code can't be registered again.
+func (st *SQLStore) DeleteLink(ctx context.Context, shortCode, userID string) error {
+	err := st.execOwned(ctx, shortCode,
+		"DELETE FROM url_mappings WHERE short_code = ? AND disabled_at IS NULL AND "+ownedBy,
+		shortCode, userID, userID,
+	)
+	return st.disabledMismatch(ctx, err, shortCode, ownedBy, userID, userID)
+}
+
+// PurgeExpiredLinks deletes mappings that expired before the cutoff. Disabled mappings are
+// kept so their code can't be registered again.
+func (st *SQLStore) PurgeExpiredLinks(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := st.exec(ctx,
+		"DELETE FROM url_mappings WHERE expires_at IS NOT NULL AND expires_at <= ? AND disabled_at IS NULL",
+		dbTime(cutoff),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	// SQLite doesn't enforce the cascade, so sweep up members of links deleted since the last run
+	if _, err := st.exec(ctx, "DELETE FROM link_members WHERE link_id NOT IN (SELECT id FROM url_mappings)"); err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ListLinksToCheck retrieves active mappings due an invite health check, never-checked ones first
+func (st *SQLStore) ListLinksToCheck(ctx context.Context, checkedBefore time.Time, limit int) ([]URLMapping, error) {
+	now := dbTime(time.Now())
+	rows, err := st.query(ctx, `
+		SELECT short_code, discord_url, owner_id, health, health_checked_at
+		FROM url_mappings
+		WHERE (expires_at IS NULL OR expires_at > ?)
+			AND (health_checked_at IS NULL OR health_checked_at <= ?)
+		ORDER BY health_checked_at IS NOT NULL, health_checked_at, id
+		LIMIT ?
+	`, now, dbTime(checkedBefore), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []URLMapping
+	for rows.Next() {
+		var mapping URLMapping
+		err := rows.Scan(&mapping.ShortCode, &mapping.DiscordURL, &mapping.OwnerID, &mapping.Health, &mapping.HealthCheckedAt)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, mapping)
+	}
+
+	return links, rows.Err()
+}
+
+// SetLinkHealth records the result of an invite health check for a mapping
+func (st *SQLStore) SetLinkHealth(ctx context.Context, shortCode, health string, checkedAt time.Time) error {
+	_, err := st.exec(ctx,
+		"UPDATE url_mappings SET health = ?, health_checked_at = ? WHERE short_code = ?",
+		health, dbTime(checkedAt), shortCode,
+	)
+	return err
+}
+
+// CreateAPIToken stores a new personal access token digest for a user
+func (st *SQLStore) CreateAPIToken(ctx context.Context, userID, name, tokenHash string) error {
+	_, err := st.exec(ctx,
+		"INSERT INTO api_tokens (user_id, name, token_hash) VALUES (?, ?, ?)",
+		userID, name, tokenHash,
+	)
+	return err
+}
+
+// ListAPITokens retrieves all personal access tokens for a specific user
+func (st *SQLStore) ListAPITokens(ctx context.Context, userID string) ([]APIToken, error) {
+	rows, err := st.query(ctx, `
+		SELECT id, name, created_at, last_used_at
+		FROM api_tokens
+		WHERE user_id = ?
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []APIToken
+	for rows.Next() {
+		var token APIToken
+		err := rows.Scan(&token.ID, &token.Name, &token.CreatedAt, &token.LastUsedAt)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// GetUserByAPIToken retrieves the user owning a personal access token digest
+func (st *SQLStore) GetUserByAPIToken(ctx context.Context, tokenHash string) (*User, error) {
+	var user User
+	err := st.queryRow(ctx, `
+		SELECT u.id, u.username, u.global_name, u.avatar, u.discriminator, u.created_at
+		FROM users u
+		JOIN api_tokens t ON u.id = t.user_id
+		WHERE t.token_hash = ? AND u.disabled_at IS NULL
+	`, tokenHash).Scan(&user.ID, &user.Username, &user.GlobalName, &user.Avatar, &user.Discriminator, &user.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	// Record usage so owners can spot stale tokens
+	st.exec(ctx, "UPDATE api_tokens SET last_used_at = ? WHERE token_hash = ?", dbTime(time.Now()), tokenHash)
+
+	return &user, nil
+}
+
+// DeleteAPIToken revokes a personal access token owned by a specific user
+func (st *SQLStore) DeleteAPIToken(ctx context.Context, tokenID int, userID string) error {
+	result, err := st.exec(ctx,
+		"DELETE FROM api_tokens WHERE id = ? AND user_id = ?",
+		tokenID, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetManagedGuilds replaces the guilds a user can manage in a single transaction
+func (st *SQLStore) SetManagedGuilds(ctx context.Context, userID string, guildIDs []string) error {
+	tx, err := st.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, st.rebind("DELETE FROM user_guilds WHERE user_id = ?"), userID); err != nil {
+		return err
+	}
+	for _, guildID := range guildIDs {
+		if _, err := tx.ExecContext(ctx,
+			st.rebind("INSERT INTO user_guilds (user_id, guild_id) VALUES (?, ?) ON CONFLICT DO NOTHING"),
+			userID, guildID,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ManagesGuild reports whether a guild was among those a user could manage at login
+func (st *SQLStore) ManagesGuild(ctx context.Context, userID, guildID string) (bool, error) {
+	var count int
+	err := st.queryRow(ctx,
+		"SELECT COUNT(*) FROM user_guilds WHERE user_id = ? AND guild_id = ?",
+		userID, guildID,
+	).Scan(&count)
+	return count > 0, err
+}
+
+// CreateSession stores a new session for a user
+func (st *SQLStore) CreateSession(ctx context.Context, tokenHash, userID string, expiresAt time.Time, userAgent, ipAddress string) error {
+	now := dbTime(time.Now())
+	_, err := st.exec(ctx, `
+		INSERT INTO sessions (id, user_id, created_at, last_seen_at, expires_at, user_agent, ip_address)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, tokenHash, userID, now, now, dbTime(expiresAt), userAgent, ipAddress)
+	return err
+}
+
+// DeleteSession removes a session from the database
+func (st *SQLStore) DeleteSession(ctx context.Context, tokenHash string) error {
+	_, err := st.exec(ctx, "DELETE FROM sessions WHERE id = ?", tokenHash)
+	return err
+}
+
+// DeleteOtherSessions removes every session of a user except the one they are using
+func (st *SQLStore) DeleteOtherSessions(ctx context.Context, userID, keepTokenHash string) (int64, error) {
+	result, err := st.exec(ctx, "DELETE FROM sessions WHERE user_id = ? AND id <> ?", userID, keepTokenHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// PurgeExpiredSessions removes every session past its expiry or idle for too long
+func (st *SQLStore) PurgeExpiredSessions(ctx context.Context, idleSince time.Time) (int64, error) {
+	result, err := st.exec(ctx,
+		"DELETE FROM sessions WHERE expires_at <= ? OR last_seen_at <= ?",
+		dbTime(time.Now()), dbTime(idleSince),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetSessionUser retrieves a user by the digest of their session token
+func (st *SQLStore) GetSessionUser(ctx context.Context, tokenHash string, idleSince time.Time) (*User, error) {
+	var user User
+	err := st.queryRow(ctx, `
+		SELECT u.id, u.username, u.global_name, u.avatar, u.discriminator, u.created_at
+		FROM users u
+		JOIN sessions s ON u.id = s.user_id
+		WHERE s.id = ? AND s.expires_at > ? AND s.last_seen_at > ? AND u.disabled_at IS NULL
+	`, tokenHash, dbTime(time.Now()), dbTime(idleSince)).Scan(&user.ID, &user.Username, &user.GlobalName, &user.Avatar, &user.Discriminator, &user.CreatedAt)
+
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	// Record activity, skipping the write if it was recorded recently
+	now := time.Now()
+	st.exec(ctx,
+		"UPDATE sessions SET last_seen_at = ? WHERE id = ? AND last_seen_at < ?",
+		dbTime(now), tokenHash, dbTime(now.Add(-sessionTouchInterval)),
+	)
+
+	return &user, nil
+}
+
+// ListUserSessions retrieves a user's active sessions, most recently used first
+func (st *SQLStore) ListUserSessions(ctx context.Context, userID string, idleSince time.Time) ([]Session, error) {
+	rows, err := st.query(ctx, `
+		SELECT id, user_id, created_at, last_seen_at, expires_at, user_agent, ip_address
+		FROM sessions
+		WHERE user_id = ? AND expires_at > ? AND last_seen_at > ?
+		ORDER BY last_seen_at DESC
+	`, userID, dbTime(time.Now()), dbTime(idleSince))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []Session
+	for rows.Next() {
+		var session Session
+		var userAgent, ipAddress *string
+		err := rows.Scan(&session.ID, &session.UserID, &session.CreatedAt, &session.LastSeenAt,
+			&session.ExpiresAt, &userAgent, &ipAddress)
+		if err != nil {
+			return nil, err
+		}
+		if userAgent != nil {
+			session.UserAgent = *userAgent
+		}
+		if ipAddress != nil {
+			session.IPAddress = *ipAddress
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// Sortable columns of the admin listings, keyed by the sort names used in URLs
+var (
+	adminLinkSorts = map[string]string{
+		"code":    "m.short_code",
+		"created": "m.created_at",
+		"expires": "m.expires_at",
+		"owner":   "u.username",
+		"health":  "m.health",
+	}
+	adminUserSorts = map[string]string{
+		"username": "u.username",
+		"created":  "u.created_at",
+		"links":    "link_count",
+	}
+)
+
+// adminOrder builds the ORDER BY clause for an admin listing, defaulting to newest first
+func adminOrder(sorts map[string]string, defaultColumn string, query AdminQuery) string {
+	column, ok := sorts[query.Sort]
+	if !ok {
+		return " ORDER BY " + defaultColumn + " DESC"
+	}
+	if query.Desc {
+		return " ORDER BY " + column + " DESC"
+	}
+	return " ORDER BY " + column + " ASC"
+}
+
+// likePattern returns a case-insensitive LIKE pattern matching text anywhere, with wildcards escaped
+func likePattern(text string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	return "%" + replacer.Replace(strings.ToLower(text)) + "%"
+}
+
+// ListAllLinks retrieves links from every owner for the admin panel
+func (st *SQLStore) ListAllLinks(ctx context.Context, query AdminQuery) ([]AdminLink, error) {
+	sqlQuery := `
+		SELECT m.short_code, m.discord_url, m.created_at, m.updated_at, m.expires_at, m.owner_id, m.guild_name, m.health, m.disabled_at, u.username
+		FROM url_mappings m
+		LEFT JOIN users u ON u.id = m.owner_id`
+	var args []interface{}
+	if query.Search != "" {
+		sqlQuery += `
+		WHERE LOWER(m.short_code) LIKE ? ESCAPE '\' OR LOWER(m.discord_url) LIKE ? ESCAPE '\'
+			OR LOWER(m.guild_name) LIKE ? ESCAPE '\' OR LOWER(u.username) LIKE ? ESCAPE '\' OR m.owner_id = ?`
+		pattern := likePattern(query.Search)
+		args = append(args, pattern, pattern, pattern, pattern, query.Search)
+	}
+	sqlQuery += adminOrder(adminLinkSorts, "m.created_at", query) + " LIMIT ?"
+	args = append(args, query.Limit)
+
+	rows, err := st.query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []AdminLink
+	for rows.Next() {
+		var link AdminLink
+		err := rows.Scan(&link.ShortCode, &link.DiscordURL, &link.CreatedAt, &link.UpdatedAt, &link.ExpiresAt, &link.OwnerID, &link.GuildName, &link.Health, &link.DisabledAt, &link.OwnerUsername)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+
+	return links, rows.Err()
+}
+
+// ListUsers retrieves users and how many links each owns for the admin panel
+func (st *SQLStore) ListUsers(ctx context.Context, query AdminQuery) ([]UserSummary, error) {
+	sqlQuery := `
+		SELECT u.id, u.username, u.global_name, u.avatar, u.discriminator, u.created_at, u.disabled_at, COUNT(m.id) AS link_count
+		FROM users u
+		LEFT JOIN url_mappings m ON m.owner_id = u.id`
+	var args []interface{}
+	if query.Search != "" {
+		sqlQuery += `
+		WHERE LOWER(u.username) LIKE ? ESCAPE '\' OR LOWER(u.global_name) LIKE ? ESCAPE '\' OR u.id = ?`
+		args = append(args, likePattern(query.Search), likePattern(query.Search), query.Search)
+	}
+	sqlQuery += `
+		GROUP BY u.id, u.username, u.global_name, u.avatar, u.discriminator, u.created_at, u.disabled_at` +
+		adminOrder(adminUserSorts, "u.created_at", query) + " LIMIT ?"
+	args = append(args, query.Limit)
+
+	rows, err := st.query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []UserSummary
+	for rows.Next() {
+		var user UserSummary
+		err := rows.Scan(&user.ID, &user.Username, &user.GlobalName, &user.Avatar, &user.Discriminator, &user.CreatedAt, &user.DisabledAt, &user.LinkCount)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// GetUser retrieves a user by ID whether or not they are disabled
+func (st *SQLStore) GetUser(ctx context.Context, userID string) (*User, error) {
+	var user User
+	err := st.queryRow(ctx,
+		"SELECT id, username, global_name, avatar, discriminator, created_at, disabled_at FROM users WHERE id = ?",
+		userID,
+	).Scan(&user.ID, &user.Username, &user.GlobalName, &user.Avatar, &user.Discriminator, &user.CreatedAt, &user.DisabledAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+// execFound runs a statement, mapping "no rows changed" to ErrNotFound
+func (st *SQLStore) execFound(ctx context.Context, query string, args ...interface{}) error {
+	result, err := st.exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// AdminDeleteLink deletes a URL mapping regardless of its owner
+func (st *SQLStore) AdminDeleteLink(ctx context.Context, shortCode string) error {
+	return st.execFound(ctx, "DELETE FROM url_mappings WHERE short_code = ?", shortCode)
+}
+
+// ReassignLink transfers a URL mapping to another existing user
+func (st *SQLStore) ReassignLink(ctx context.Context, shortCode, newOwnerID string) error {
+	if _, err := st.GetUser(ctx, newOwnerID); err != nil {
+		return err
+	}
+	return st.execFound(ctx,
+		"UPDATE url_mappings SET owner_id = ? WHERE short_code = ?",
+		newOwnerID, shortCode,
+	)
+}
+
+// SetUserDisabled disables or re-enables a user, deleting their sessions when disabling
+func (st *SQLStore) SetUserDisabled(ctx context.Context, userID string, disabled bool) error {
+	if err := st.execFound(ctx, "UPDATE users SET disabled_at = ? WHERE id = ?", disabledAt(disabled), userID); err != nil {
+		return err
+	}
+	if disabled {
+		_, err := st.exec(ctx, "DELETE FROM sessions WHERE user_id = ?", userID)
+		return err
+	}
+	return nil
+}
+
+// disabledAt returns the value stored in a disabled_at column
+func disabledAt(disabled bool) *string {
+	if !disabled {
+		return nil
+	}
+	now := dbTime(time.Now())
+	return &now
+}
+
+// SetLinkDisabled disables or re-enables a URL mapping regardless of its owner
+func (st *SQLStore) SetLinkDisabled(ctx context.Context, shortCode string, disabled bool) error {
+	return st.execFound(ctx,
+		"UPDATE url_mappings SET disabled_at = ? WHERE short_code = ?",
+		disabledAt(disabled), shortCode,
+	)
+}
+
+// DisableUserLinks disables every URL mapping owned by a user that isn't already disabled
+func (st *SQLStore) DisableUserLinks(ctx context.Context, userID string) (int64, error) {
+	result, err := st.exec(ctx,
+		"UPDATE url_mappings SET disabled_at = ? WHERE owner_id = ? AND disabled_at IS NULL",
+		disabledAt(true), userID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// CreateReport stores a new open abuse report
+func (st *SQLStore) CreateReport(ctx context.Context, report *Report) error {
+	_, err := st.exec(ctx, `
+		INSERT INTO reports (short_code, discord_url, owner_id, reason, details, reporter_id, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, report.ShortCode, report.DiscordURL, report.OwnerID, report.Reason, report.Details, report.ReporterID, ReportOpen, dbTime(time.Now()))
+	return err
+}
+
+// reportColumns are the columns read by scanReport
+const reportColumns = "id, short_code, discord_url, owner_id, reason, details, reporter_id, status, created_at, resolved_at, resolved_by"
+
+// scanReport reads a report selected with reportColumns
+func scanReport(scan func(dest ...interface{}) error) (Report, error) {
+	var report Report
+	err := scan(&report.ID, &report.ShortCode, &report.DiscordURL, &report.OwnerID, &report.Reason, &report.Details,
+		&report.ReporterID, &report.Status, &report.CreatedAt, &report.ResolvedAt, &report.ResolvedBy)
+	return report, err
+}
+
+// ListReports retrieves reports with a status, oldest first so the queue is worked in order
+func (st *SQLStore) ListReports(ctx context.Context, status string, limit int) ([]Report, error) {
+	rows, err := st.query(ctx,
+		"SELECT "+reportColumns+" FROM reports WHERE status = ? ORDER BY created_at, id LIMIT ?",
+		status, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reports []Report
+	for rows.Next() {
+		report, err := scanReport(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, rows.Err()
+}
+
+// GetReport retrieves a single abuse report
+func (st *SQLStore) GetReport(ctx context.Context, reportID int) (*Report, error) {
+	report, err := scanReport(st.queryRow(ctx, "SELECT "+reportColumns+" FROM reports WHERE id = ?", reportID).Scan)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &report, nil
+}
+
+// ResolveReport closes an open abuse report
+func (st *SQLStore) ResolveReport(ctx context.Context, reportID int, status, resolvedBy string) error {
+	return st.execFound(ctx,
+		"UPDATE reports SET status = ?, resolved_at = ?, resolved_by = ? WHERE id = ? AND status = ?",
+		status, dbTime(time.Now()), resolvedBy, reportID, ReportOpen,
+	)
+}
+
+// ResolveLinkReports closes every open abuse report against a short code
+func (st *SQLStore) ResolveLinkReports(ctx context.Context, shortCode, status, resolvedBy string) (int64, error) {
+	result, err := st.exec(ctx,
+		"UPDATE reports SET status = ?, resolved_at = ?, resolved_by = ? WHERE short_code = ? AND status = ?",
+		status, dbTime(time.Now()), resolvedBy, shortCode, ReportOpen,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// insertAuditEvent is the statement that appends to the audit log
+const insertAuditEvent = `
+	INSERT INTO audit_events (actor_id, action, short_code, subject_id, old_url, new_url, details, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+// auditEventArgs returns the arguments for insertAuditEvent
+func auditEventArgs(event *AuditEvent) []interface{} {
+	return []interface{}{event.ActorID, event.Action, event.ShortCode, event.SubjectID, event.OldURL, event.NewURL, event.Details, dbTime(time.Now())}
+}
+
+// RecordAuditEvent appends an event to the audit log
+func (st *SQLStore) RecordAuditEvent(ctx context.Context, event *AuditEvent) error {
+	_, err := st.exec(ctx, insertAuditEvent, auditEventArgs(event)...)
+	return err
+}
+
+// ListAuditEvents retrieves audit events matching the query, newest first
+func (st *SQLStore) ListAuditEvents(ctx context.Context, query AuditQuery) ([]AuditEvent, error) {
+	var conditions []string
+	var args []interface{}
+	if query.UserID != "" {
+		conditions = append(conditions, "(actor_id = ? OR subject_id = ?)")
+		args = append(args, query.UserID, query.UserID)
+	}
+	if query.Action != "" {
+		conditions = append(conditions, "action = ?")
+		args = append(args, query.Action)
+	}
+	if query.ShortCode != "" {
+		conditions = append(conditions, "short_code = ?")
+		args = append(args, query.ShortCode)
+	}
+	if !query.Since.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, dbTime(query.Since))
+	}
+	if !query.Until.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, dbTime(query.Until))
+	}
+
+	sqlQuery := "SELECT id, actor_id, action, short_code, subject_id, old_url, new_url, details, created_at FROM audit_events"
+	if len(conditions) > 0 {
+		sqlQuery += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	sqlQuery += " ORDER BY created_at DESC, id DESC LIMIT ?"
+	args = append(args, query.Limit)
+
+	rows, err := st.query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []AuditEvent
+	for rows.Next() {
+		var event AuditEvent
+		err := rows.Scan(&event.ID, &event.ActorID, &event.Action, &event.ShortCode, &event.SubjectID,
+			&event.OldURL, &event.NewURL, &event.Details, &event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// OfferTransfer offers a mapping owned by fromUserID to another user, replacing any pending
+// offer. Disabled mappings can't be offered.
+func (st *SQLStore) OfferTransfer(ctx context.Context, shortCode, fromUserID, toUserID string, expiresAt time.Time) error {
+	err := st.execOwned(ctx, shortCode, `
+		INSERT INTO link_transfers (short_code, from_user_id, to_user_id, created_at, expires_at)
+		SELECT short_code, owner_id, ?, ?, ? FROM url_mappings WHERE short_code = ? AND disabled_at IS NULL AND owner_id = ?
+		ON CONFLICT (short_code) DO UPDATE SET
+			from_user_id = excluded.from_user_id,
+			to_user_id = excluded.to_user_id,
+			created_at = excluded.created_at,
+			expires_at = excluded.expires_at
+	`, toUserID, dbTime(time.Now()), dbTime(expiresAt), shortCode, fromUserID)
+	return st.disabledMismatch(ctx, err, shortCode, "owner_id = ?", fromUserID)
+}
+
+// pendingTransfers selects unexpired offers whose link still belongs to the user who made them
+const pendingTransfers = `
+	SELECT t.short_code, m.discord_url, t.from_user_id, COALESCE(u.username, ''), t.to_user_id, t.created_at, t.expires_at
+	FROM link_transfers t
+	JOIN url_mappings m ON m.short_code = t.short_code AND m.owner_id = t.from_user_id
+	LEFT JOIN users u ON u.id = t.from_user_id
+	WHERE t.expires_at > ?`
+
+// listTransfers retrieves pending offers matching an extra condition on link_transfers
+func (st *SQLStore) listTransfers(ctx context.Context, condition string, args ...interface{}) ([]LinkTransfer, error) {
+	rows, err := st.query(ctx,
+		pendingTransfers+" AND "+condition+" ORDER BY t.created_at DESC",
+		append([]interface{}{dbTime(time.Now())}, args...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transfers []LinkTransfer
+	for rows.Next() {
+		var transfer LinkTransfer
+		err := rows.Scan(&transfer.ShortCode, &transfer.DiscordURL, &transfer.FromUserID, &transfer.FromUsername,
+			&transfer.ToUserID, &transfer.CreatedAt, &transfer.ExpiresAt)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, transfer)
+	}
+
+	return transfers, rows.Err()
+}
+
+// ListIncomingTransfers retrieves pending offers made to a user
+func (st *SQLStore) ListIncomingTransfers(ctx context.Context, userID string) ([]LinkTransfer, error) {
+	return st.listTransfers(ctx, "t.to_user_id = ?", userID)
+}
+
+// ListOutgoingTransfers retrieves pending offers a user has made
+func (st *SQLStore) ListOutgoingTransfers(ctx context.Context, userID string) ([]LinkTransfer, error) {
+	return st.listTransfers(ctx, "t.from_user_id = ?", userID)
+}
+
+// removeTransfer deletes a pending offer matching an extra condition, returning what was removed
+func (st *SQLStore) removeTransfer(ctx context.Context, shortCode, condition, userID string) (*LinkTransfer, error) {
+	transfers, err := st.listTransfers(ctx, "t.short_code = ? AND "+condition, shortCode, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(transfers) == 0 {
+		return nil, ErrNotFound
+	}
+
+	if _, err := st.exec(ctx, "DELETE FROM link_transfers WHERE short_code = ?", shortCode); err != nil {
+		return nil, err
+	}
+	return &transfers[0], nil
+}
+
+// CancelTransfer withdraws an offer made by fromUserID
+func (st *SQLStore) CancelTransfer(ctx context.Context, shortCode, fromUserID string) (*LinkTransfer, error) {
+	return st.removeTransfer(ctx, shortCode, "t.from_user_id = ?", fromUserID)
+}
+
+// DeclineTransfer refuses an offer made to toUserID
+func (st *SQLStore) DeclineTransfer(ctx context.Context, shortCode, toUserID string) (*LinkTransfer, error) {
+	return st.removeTransfer(ctx, shortCode, "t.to_user_id = ?", toUserID)
+}
+
+// AcceptTransfer moves a mapping to the user it was offered to, removes the offer and records
+// the change in the audit log in a single transaction
+func (st *SQLStore) AcceptTransfer(ctx context.Context, shortCode, toUserID string) (*LinkTransfer, error) {
+	tx, err := st.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var transfer LinkTransfer
+	err = tx.QueryRowContext(ctx, st.rebind(pendingTransfers+" AND t.short_code = ? AND t.to_user_id = ?"),
+		dbTime(time.Now()), shortCode, toUserID,
+	).Scan(&transfer.ShortCode, &transfer.DiscordURL, &transfer.FromUserID, &transfer.FromUsername,
+		&transfer.ToUserID, &transfer.CreatedAt, &transfer.ExpiresAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	// The owner check guards against the link changing hands since the offer was read
+	result, err := tx.ExecContext(ctx,
+		st.rebind("UPDATE url_mappings SET owner_id = ? WHERE short_code = ? AND owner_id = ?"),
+		toUserID, shortCode, transfer.FromUserID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if rowsAffected == 0 {
+		return nil, ErrNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, st.rebind("DELETE FROM link_transfers WHERE short_code = ?"), shortCode); err != nil {
+		return nil, err
+	}
+
+	event := newAuditEvent(toUserID, AuditTransferAccept, shortCode)
+	event.SubjectID = &transfer.FromUserID
+	if _, err := tx.ExecContext(ctx, st.rebind(insertAuditEvent), auditEventArgs(event)...); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// LinkRole returns the role a user has on a mapping
+func (st *SQLStore) LinkRole(ctx context.Context, shortCode, userID string) (string, error) {
+	var ownerID, role *string
+	err := st.queryRow(ctx, `
+		SELECT m.owner_id, lm.role
+		FROM url_mappings m
+		LEFT JOIN link_members lm ON lm.link_id = m.id AND lm.user_id = ? AND lm.accepted_at IS NOT NULL
+		WHERE m.short_code = ?
+	`, userID, shortCode).Scan(&ownerID, &role)
+	if err != nil {
+		return "", notFound(err)
+	}
+	if role := resolveRole(ownerID, role, userID); role != "" {
+		return role, nil
+	}
+	return "", ErrNotOwner
+}
+
+// InviteMember invites a user to co-manage a mapping the inviter owns or co-owns, unless it is
+// disabled. A different role for an existing member is a new invitation they must accept.
+func (st *SQLStore) InviteMember(ctx context.Context, shortCode, inviterID, userID, role string) error {
+	err := st.execOwned(ctx, shortCode, `
+		INSERT INTO link_members (link_id, user_id, role, invited_by, created_at)
+		SELECT id, ?, ?, ?, ? FROM url_mappings WHERE short_code = ? AND disabled_at IS NULL AND `+ownedBy+`
+		ON CONFLICT (link_id, user_id) DO UPDATE SET
+			accepted_at = CASE WHEN link_members.role = excluded.role THEN link_members.accepted_at END,
+			role = excluded.role,
+			invited_by = excluded.invited_by
+	`, userID, role, inviterID, dbTime(time.Now()), shortCode, inviterID, inviterID)
+	return st.disabledMismatch(ctx, err, shortCode, ownedBy, inviterID, inviterID)
+}
+
+// listMembers retrieves members matching a condition, leaving out whoever now owns the link
+func (st *SQLStore) listMembers(ctx context.Context, condition string, args ...interface{}) ([]LinkMember, error) {
+	rows, err := st.query(ctx, `
+		SELECT m.short_code, m.discord_url, lm.user_id, COALESCE(u.username, ''), lm.role, lm.invited_by, lm.created_at, lm.accepted_at
+		FROM link_members lm
+		JOIN url_mappings m ON m.id = lm.link_id AND m.owner_id <> lm.user_id
+		LEFT JOIN users u ON u.id = lm.user_id
+		WHERE `+condition+`
+		ORDER BY lm.created_at
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []LinkMember
+	for rows.Next() {
+		var member LinkMember
+		err := rows.Scan(&member.ShortCode, &member.DiscordURL, &member.UserID, &member.Username,
+			&member.Role, &member.InvitedBy, &member.CreatedAt, &member.AcceptedAt)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+// ListLinkMembers retrieves a mapping's members and pending invitations
+func (st *SQLStore) ListLinkMembers(ctx context.Context, shortCode string) ([]LinkMember, error) {
+	return st.listMembers(ctx, "m.short_code = ?", shortCode)
+}
+
+// ListMemberInvites retrieves the invitations a user has not yet accepted
+func (st *SQLStore) ListMemberInvites(ctx context.Context, userID string) ([]LinkMember, error) {
+	return st.listMembers(ctx, "lm.user_id = ? AND lm.accepted_at IS NULL", userID)
+}
+
+// AcceptMemberInvite makes a pending invitation take effect
+func (st *SQLStore) AcceptMemberInvite(ctx context.Context, shortCode, userID string) error {
+	return st.execFound(ctx, `
+		UPDATE link_members SET accepted_at = ?
+		WHERE user_id = ? AND accepted_at IS NULL
+			AND link_id = (SELECT id FROM url_mappings WHERE short_code = ?)
+	`, dbTime(time.Now()), userID, shortCode)
+}
+
+// RemoveMember removes a member or invitation from a mapping
+func (st *SQLStore) RemoveMember(ctx context.Context, shortCode, removerID, userID string) error {
+	if removerID != userID {
+		role, err := st.LinkRole(ctx, shortCode, removerID)
+		if err != nil {
+			return err
+		}
+		if role != RoleOwner {
+			return ErrNotOwner
+		}
+	}
+	return st.execFound(ctx,
+		"DELETE FROM link_members WHERE user_id = ? AND link_id = (SELECT id FROM url_mappings WHERE short_code = ?)",
+		userID, shortCode,
+	)
+}
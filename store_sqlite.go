@@ -0,0 +1,63 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqliteDialect stores data in a local SQLite database file
+var sqliteDialect = &sqlDialect{
+	name:          "sqlite",
+	driver:        "sqlite",
+	migrationsDir: "migrations/sqlite",
+	migrationsTable: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`,
+	isUniqueViolation: func(err error) bool {
+		var sqliteErr *sqlite.Error
+		return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+	},
+	// Databases created before schema_migrations existed had their schema created ad hoc at startup
+	legacyMarkers: map[int]func(st *SQLStore) (bool, error){
+		1: func(st *SQLStore) (bool, error) { return st.sqliteTableExists("url_mappings") },
+		2: func(st *SQLStore) (bool, error) { return st.sqliteTableExists("api_tokens") },
+		3: func(st *SQLStore) (bool, error) { return st.sqliteColumnExists("url_mappings", "updated_at") },
+		4: func(st *SQLStore) (bool, error) { return st.sqliteColumnExists("url_mappings", "expiry_days") },
+	},
+}
+
+func init() {
+	// SQLite has no built-in hash functions; migrations use this one to hash existing secrets
+	sqlite.MustRegisterDeterministicScalarFunction("sha256_hex", 1,
+		func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+			var value []byte
+			switch arg := args[0].(type) {
+			case string:
+				value = []byte(arg)
+			case []byte:
+				value = arg
+			case nil:
+				return nil, nil
+			default:
+				return nil, fmt.Errorf("sha256_hex: unsupported argument type %T", arg)
+			}
+			sum := sha256.Sum256(value)
+			return hex.EncodeToString(sum[:]), nil
+		})
+}
+
+// OpenSQLiteStore opens the SQLite database at dbPath
+func OpenSQLiteStore(dbPath string) (*SQLStore, error) {
+	// Wait for locks rather than failing immediately, since background jobs write concurrently with requests
+	return openSQLStore(sqliteDialect, dbPath+"?_pragma=busy_timeout(5000)")
+}
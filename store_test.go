@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// forEachStore runs test against a fresh MemoryStore and a fresh SQLite SQLStore, seeded with
+// users u1, u2 and u3, so both implementations are held to the same behaviour
+func forEachStore(t *testing.T, test func(t *testing.T, store Store)) {
+	stores := map[string]func(t *testing.T) Store{
+		"memory": func(t *testing.T) Store {
+			return NewMemoryStore()
+		},
+		"sqlite": func(t *testing.T) Store {
+			store, err := OpenSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+			if err != nil {
+				t.Fatalf("OpenSQLiteStore: %v", err)
+			}
+			if _, err := store.Migrate(); err != nil {
+				t.Fatalf("Migrate: %v", err)
+			}
+			return store
+		},
+	}
+
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			t.Cleanup(func() { store.Close() })
+
+			for _, id := range []string{"u1", "u2", "u3"} {
+				if err := store.UpsertUser(context.Background(), &User{ID: id, Username: id}); err != nil {
+					t.Fatalf("UpsertUser: %v", err)
+				}
+			}
+			test(t, store)
+		})
+	}
+}
+
+// mustCreateLink registers a link for ownerID, failing the test if it can't
+func mustCreateLink(t *testing.T, store Store, shortCode, ownerID string, expiry LinkExpiry) {
+	t.Helper()
+	if err := store.CreateLink(context.Background(), shortCode, "https://discord.gg/"+shortCode, ownerID, expiry, nil); err != nil {
+		t.Fatalf("CreateLink(%s): %v", shortCode, err)
+	}
+}
+
+func TestStoreCodeTaken(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		mustCreateLink(t, store, "abc", "u1", LinkExpiry{})
+
+		err := store.CreateLink(context.Background(), "abc", "https://discord.gg/other", "u2", LinkExpiry{}, nil)
+		if !errors.Is(err, ErrCodeTaken) {
+			t.Errorf("registering a taken code: got %v, want ErrCodeTaken", err)
+		}
+	})
+}
+
+func TestStoreNotFound(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+
+		if _, err := store.GetLink(ctx, "nope"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetLink: got %v, want ErrNotFound", err)
+		}
+		if _, err := store.GetLinkRecord(ctx, "nope"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetLinkRecord: got %v, want ErrNotFound", err)
+		}
+		if _, err := store.ResolveLink(ctx, "nope"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("ResolveLink: got %v, want ErrNotFound", err)
+		}
+		if err := store.UpdateLinkURL(ctx, "nope", "u1", "https://discord.gg/x", nil); !errors.Is(err, ErrNotFound) {
+			t.Errorf("UpdateLinkURL: got %v, want ErrNotFound", err)
+		}
+		if err := store.SetLinkExpiry(ctx, "nope", "u1", LinkExpiry{}); !errors.Is(err, ErrNotFound) {
+			t.Errorf("SetLinkExpiry: got %v, want ErrNotFound", err)
+		}
+		if err := store.DeleteLink(ctx, "nope", "u1"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("DeleteLink: got %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestStoreNotOwner(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		mustCreateLink(t, store, "abc", "u1", LinkExpiry{})
+
+		if err := store.UpdateLinkURL(ctx, "abc", "u2", "https://discord.gg/stolen", nil); !errors.Is(err, ErrNotOwner) {
+			t.Errorf("UpdateLinkURL: got %v, want ErrNotOwner", err)
+		}
+		if err := store.SetLinkExpiry(ctx, "abc", "u2", expiryInDays(7)); !errors.Is(err, ErrNotOwner) {
+			t.Errorf("SetLinkExpiry: got %v, want ErrNotOwner", err)
+		}
+		if err := store.DeleteLink(ctx, "abc", "u2"); !errors.Is(err, ErrNotOwner) {
+			t.Errorf("DeleteLink: got %v, want ErrNotOwner", err)
+		}
+
+		mapping, err := store.GetLink(ctx, "abc")
+		if err != nil {
+			t.Fatalf("GetLink: %v", err)
+		}
+		if mapping.DiscordURL != "https://discord.gg/abc" || mapping.ExpiresAt != nil {
+			t.Errorf("refused changes were applied: %+v", mapping)
+		}
+	})
+}
+
+func TestStoreExpiryFiltering(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		expired := time.Now().Add(-time.Hour)
+		mustCreateLink(t, store, "old", "u1", LinkExpiry{ExpiresAt: &expired})
+		mustCreateLink(t, store, "new", "u1", expiryInDays(7))
+
+		if _, err := store.GetLink(ctx, "old"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetLink of an expired link: got %v, want ErrNotFound", err)
+		}
+		if _, err := store.ResolveLink(ctx, "old"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("ResolveLink of an expired link: got %v, want ErrNotFound", err)
+		}
+		if _, err := store.GetLinkRecord(ctx, "old"); err != nil {
+			t.Errorf("GetLinkRecord of an expired link: %v", err)
+		}
+		if url, err := store.ResolveLink(ctx, "new"); err != nil || url != "https://discord.gg/new" {
+			t.Errorf("ResolveLink of an active link = %q, %v", url, err)
+		}
+
+		// Owners still see their expired links so they can renew them
+		links, err := store.ListUserLinks(ctx, "u1")
+		if err != nil {
+			t.Fatalf("ListUserLinks: %v", err)
+		}
+		if len(links) != 2 {
+			t.Errorf("ListUserLinks returned %d links, want 2", len(links))
+		}
+
+		purged, err := store.PurgeExpiredLinks(ctx, time.Now())
+		if err != nil {
+			t.Fatalf("PurgeExpiredLinks: %v", err)
+		}
+		if purged != 1 {
+			t.Errorf("PurgeExpiredLinks removed %d links, want 1", purged)
+		}
+		if _, err := store.GetLinkRecord(ctx, "old"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("purged link is still there: %v", err)
+		}
+		if _, err := store.GetLink(ctx, "new"); err != nil {
+			t.Errorf("active link was purged: %v", err)
+		}
+	})
+}
+
+func TestStoreMemberRoles(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		mustCreateLink(t, store, "abc", "u1", LinkExpiry{})
+
+		if err := store.InviteMember(ctx, "abc", "u1", "u2", RoleEditor); err != nil {
+			t.Fatalf("InviteMember(editor): %v", err)
+		}
+		if err := store.InviteMember(ctx, "abc", "u1", "u3", RoleOwner); err != nil {
+			t.Fatalf("InviteMember(owner): %v", err)
+		}
+
+		// Invitations grant nothing until they are accepted
+		if err := store.UpdateLinkURL(ctx, "abc", "u2", "https://discord.gg/early", nil); !errors.Is(err, ErrNotOwner) {
+			t.Errorf("pending editor UpdateLinkURL: got %v, want ErrNotOwner", err)
+		}
+
+		for _, id := range []string{"u2", "u3"} {
+			if err := store.AcceptMemberInvite(ctx, "abc", id); err != nil {
+				t.Fatalf("AcceptMemberInvite(%s): %v", id, err)
+			}
+		}
+
+		if role, err := store.LinkRole(ctx, "abc", "u2"); err != nil || role != RoleEditor {
+			t.Errorf("LinkRole(u2) = %q, %v; want %q", role, err, RoleEditor)
+		}
+
+		// Editors can change the destination but not delete the link
+		if err := store.UpdateLinkURL(ctx, "abc", "u2", "https://discord.gg/edited", nil); err != nil {
+			t.Errorf("editor UpdateLinkURL: %v", err)
+		}
+		if err := store.SetLinkExpiry(ctx, "abc", "u2", expiryInDays(30)); err != nil {
+			t.Errorf("editor SetLinkExpiry: %v", err)
+		}
+		if err := store.DeleteLink(ctx, "abc", "u2"); !errors.Is(err, ErrNotOwner) {
+			t.Errorf("editor DeleteLink: got %v, want ErrNotOwner", err)
+		}
+
+		links, err := store.ListUserLinks(ctx, "u2")
+		if err != nil {
+			t.Fatalf("ListUserLinks: %v", err)
+		}
+		if len(links) != 1 || links[0].Role != RoleEditor || links[0].DiscordURL != "https://discord.gg/edited" {
+			t.Errorf("ListUserLinks(u2) = %+v, want abc as editor with the edited URL", links)
+		}
+
+		// Co-owners can delete it
+		if err := store.DeleteLink(ctx, "abc", "u3"); err != nil {
+			t.Errorf("co-owner DeleteLink: %v", err)
+		}
+		if _, err := store.GetLinkRecord(ctx, "abc"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("link survived deletion: %v", err)
+		}
+	})
+}
+
+func TestStoreDisabledLinks(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		expired := time.Now().Add(-time.Hour)
+		mustCreateLink(t, store, "abc", "u1", LinkExpiry{})
+		mustCreateLink(t, store, "old", "u1", LinkExpiry{ExpiresAt: &expired})
+		for _, code := range []string{"abc", "old"} {
+			if err := store.SetLinkDisabled(ctx, code, true); err != nil {
+				t.Fatalf("SetLinkDisabled(%s): %v", code, err)
+			}
+		}
+
+		// The owner can no longer change the link in any way that frees its code
+		if err := store.UpdateLinkURL(ctx, "abc", "u1", "https://discord.gg/new", nil); !errors.Is(err, ErrLinkDisabled) {
+			t.Errorf("UpdateLinkURL: got %v, want ErrLinkDisabled", err)
+		}
+		if err := store.SetLinkExpiry(ctx, "abc", "u1", expiryInDays(7)); !errors.Is(err, ErrLinkDisabled) {
+			t.Errorf("SetLinkExpiry: got %v, want ErrLinkDisabled", err)
+		}
+		if err := store.DeleteLink(ctx, "abc", "u1"); !errors.Is(err, ErrLinkDisabled) {
+			t.Errorf("DeleteLink: got %v, want ErrLinkDisabled", err)
+		}
+		if err := store.OfferTransfer(ctx, "abc", "u1", "u2", time.Now().Add(time.Hour)); !errors.Is(err, ErrLinkDisabled) {
+			t.Errorf("OfferTransfer: got %v, want ErrLinkDisabled", err)
+		}
+		if err := store.InviteMember(ctx, "abc", "u1", "u2", RoleOwner); !errors.Is(err, ErrLinkDisabled) {
+			t.Errorf("InviteMember: got %v, want ErrLinkDisabled", err)
+		}
+
+		// Other users are still told the link isn't theirs
+		if err := store.SetLinkExpiry(ctx, "abc", "u2", expiryInDays(7)); !errors.Is(err, ErrNotOwner) {
+			t.Errorf("SetLinkExpiry by another user: got %v, want ErrNotOwner", err)
+		}
+
+		mapping, err := store.GetLinkRecord(ctx, "abc")
+		if err != nil {
+			t.Fatalf("GetLinkRecord: %v", err)
+		}
+		if mapping.DiscordURL != "https://discord.gg/abc" || mapping.ExpiresAt != nil {
+			t.Errorf("refused changes were applied: %+v", mapping)
+		}
+
+		// Expired disabled links outlive the purge, so their code stays taken
+		purged, err := store.PurgeExpiredLinks(ctx, time.Now())
+		if err != nil {
+			t.Fatalf("PurgeExpiredLinks: %v", err)
+		}
+		if purged != 0 {
+			t.Errorf("PurgeExpiredLinks removed %d disabled links", purged)
+		}
+		err = store.CreateLink(ctx, "old", "https://discord.gg/reclaim", "u2", LinkExpiry{}, nil)
+		if !errors.Is(err, ErrCodeTaken) {
+			t.Errorf("registering a disabled link's code: got %v, want ErrCodeTaken", err)
+		}
+	})
+}
+
+func TestStoreReinviteMember(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		mustCreateLink(t, store, "abc", "u1", LinkExpiry{})
+		if err := store.InviteMember(ctx, "abc", "u1", "u2", RoleEditor); err != nil {
+			t.Fatalf("InviteMember: %v", err)
+		}
+		if err := store.AcceptMemberInvite(ctx, "abc", "u2"); err != nil {
+			t.Fatalf("AcceptMemberInvite: %v", err)
+		}
+
+		// Repeating the same role leaves the membership as it was
+		if err := store.InviteMember(ctx, "abc", "u1", "u2", RoleEditor); err != nil {
+			t.Fatalf("InviteMember(same role): %v", err)
+		}
+		if role, err := store.LinkRole(ctx, "abc", "u2"); err != nil || role != RoleEditor {
+			t.Errorf("LinkRole after repeating the role = %q, %v; want %q", role, err, RoleEditor)
+		}
+
+		// A new role is a new invitation, with no access until it is accepted
+		if err := store.InviteMember(ctx, "abc", "u1", "u2", RoleOwner); err != nil {
+			t.Fatalf("InviteMember(new role): %v", err)
+		}
+		if role, err := store.LinkRole(ctx, "abc", "u2"); !errors.Is(err, ErrNotOwner) {
+			t.Errorf("LinkRole before accepting the new role = %q, %v; want ErrNotOwner", role, err)
+		}
+		if err := store.DeleteLink(ctx, "abc", "u2"); !errors.Is(err, ErrNotOwner) {
+			t.Errorf("DeleteLink before accepting the new role: got %v, want ErrNotOwner", err)
+		}
+		invites, err := store.ListMemberInvites(ctx, "u2")
+		if err != nil {
+			t.Fatalf("ListMemberInvites: %v", err)
+		}
+		if len(invites) != 1 || invites[0].Role != RoleOwner {
+			t.Errorf("ListMemberInvites(u2) = %+v, want one invitation as owner", invites)
+		}
+
+		if err := store.AcceptMemberInvite(ctx, "abc", "u2"); err != nil {
+			t.Fatalf("AcceptMemberInvite(new role): %v", err)
+		}
+		if role, err := store.LinkRole(ctx, "abc", "u2"); err != nil || role != RoleOwner {
+			t.Errorf("LinkRole after accepting = %q, %v; want %q", role, err, RoleOwner)
+		}
+	})
+}
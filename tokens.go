@@ -0,0 +1,122 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// APITokenPrefix marks personal access tokens so they are easy to recognise in logs and secret scanners
+const APITokenPrefix = "drp_"
+
+// GenerateAPIToken generates a random personal access token
+func generateAPIToken() string {
+	bytes := make([]byte, 32)
+	rand.Read(bytes)
+	return APITokenPrefix + hex.EncodeToString(bytes)
+}
+
+// HashAPIToken returns the digest stored in place of a personal access token
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// HandleTokens routes personal access token management requests
+func (s *Server) handleTokens(w http.ResponseWriter, r *http.Request, tokenPath string) {
+	// Check if user is authenticated
+	user, err := s.getCurrentUser(r)
+	if err != nil {
+		// Not authenticated, redirect to login
+		s.redirectToLogin(w, r)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch tokenPath {
+	case "create":
+		s.handleTokenCreate(w, r, user)
+	case "revoke":
+		s.handleTokenRevoke(w, r, user)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// HandleTokenCreate creates a personal access token and shows it once
+func (s *Server) handleTokenCreate(w http.ResponseWriter, r *http.Request, user *User) {
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		http.Error(w, "Token name is required", http.StatusBadRequest)
+		return
+	}
+
+	if len(name) > 64 {
+		http.Error(w, "Token name must be 64 characters or less", http.StatusBadRequest)
+		return
+	}
+
+	token := generateAPIToken()
+	if err := s.store.CreateAPIToken(r.Context(), user.ID, name, hashAPIToken(token)); err != nil {
+		s.renderError(w, 500, "Database Error", "Failed to create token", err.Error())
+		return
+	}
+
+	event := newAuditEvent(user.ID, AuditTokenCreate, "")
+	event.Details = "token " + name
+	s.audit(r.Context(), event)
+
+	// Only the digest is stored, so this is the one chance to copy the token
+	data := struct {
+		Name  string
+		Token string
+	}{
+		Name:  name,
+		Token: token,
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	err := s.templates.ExecuteTemplate(w, "token.html", data)
+	if err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		log.Printf("Template error: %v", err)
+	}
+}
+
+// HandleTokenRevoke revokes one of the user's personal access tokens
+func (s *Server) handleTokenRevoke(w http.ResponseWriter, r *http.Request, user *User) {
+	tokenID, err := strconv.Atoi(r.FormValue("token_id"))
+	if err != nil {
+		http.Error(w, "Token ID is required", http.StatusBadRequest)
+		return
+	}
+
+	err = s.store.DeleteAPIToken(r.Context(), tokenID, user.ID)
+	if errors.Is(err, ErrNotFound) {
+		s.renderError(w, 404, "Token Not Found",
+			"No token was revoked. It may have already been removed.",
+			"Please check your dashboard for current tokens.")
+		return
+	}
+
+	if err != nil {
+		s.renderError(w, 500, "Database Error", "Failed to revoke token", err.Error())
+		return
+	}
+
+	event := newAuditEvent(user.ID, AuditTokenRevoke, "")
+	event.Details = "token #" + strconv.Itoa(tokenID)
+	s.audit(r.Context(), event)
+
+	// Success - redirect back to dashboard (root)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// transferLifetime is how long a transfer offer waits for the recipient to accept
+const transferLifetime = 7 * 24 * time.Hour
+
+// Discord user IDs are snowflakes
+var discordUserIDRegex = regexp.MustCompile(`^[0-9]{17,20}$`)
+
+// HandleTransfers routes ownership transfer requests
+func (s *Server) handleTransfers(w http.ResponseWriter, r *http.Request, transferPath string) {
+	// Check if user is authenticated
+	user, err := s.getCurrentUser(r)
+	if err != nil {
+		// Not authenticated, redirect to login
+		s.redirectToLogin(w, r)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	shortCode := strings.ToLower(strings.TrimSpace(r.FormValue("short_code")))
+	if shortCode == "" {
+		http.Error(w, "Short code is required", http.StatusBadRequest)
+		return
+	}
+
+	switch transferPath {
+	case "offer":
+		s.handleTransferOffer(w, r, user, shortCode)
+	case "cancel":
+		s.handleTransferCancel(w, r, user, shortCode)
+	case "accept":
+		s.handleTransferAccept(w, r, user, shortCode)
+	case "decline":
+		s.handleTransferDecline(w, r, user, shortCode)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// HandleTransferOffer nominates another Discord user to take over one of the user's links
+func (s *Server) handleTransferOffer(w http.ResponseWriter, r *http.Request, user *User, shortCode string) {
+	recipientID := strings.TrimSpace(r.FormValue("to_user_id"))
+	if !discordUserIDRegex.MatchString(recipientID) {
+		http.Error(w, "Enter the recipient's numeric Discord user ID", http.StatusBadRequest)
+		return
+	}
+
+	if recipientID == user.ID {
+		http.Error(w, "You can't transfer a link to yourself", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.OfferTransfer(r.Context(), shortCode, user.ID, recipientID, time.Now().Add(transferLifetime)); err != nil {
+		s.renderLinkError(w, err, shortCode, "transfer", "Failed to offer transfer")
+		return
+	}
+	log.Printf("User %s offered link %s to user %s", user.ID, shortCode, recipientID)
+
+	event := newAuditEvent(user.ID, AuditTransferOffer, shortCode)
+	event.SubjectID = &recipientID
+	s.audit(r.Context(), event)
+
+	// Success - redirect back to dashboard (root)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// HandleTransferCancel withdraws a pending offer the user made
+func (s *Server) handleTransferCancel(w http.ResponseWriter, r *http.Request, user *User, shortCode string) {
+	transfer, err := s.store.CancelTransfer(r.Context(), shortCode, user.ID)
+	if err != nil {
+		s.renderTransferError(w, err, "Failed to cancel transfer")
+		return
+	}
+
+	event := newAuditEvent(user.ID, AuditTransferCancel, shortCode)
+	event.SubjectID = &transfer.ToUserID
+	s.audit(r.Context(), event)
+
+	// Success - redirect back to dashboard (root)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// HandleTransferAccept takes ownership of a link offered to the user
+func (s *Server) handleTransferAccept(w http.ResponseWriter, r *http.Request, user *User, shortCode string) {
+	// The store records the audit event in the same transaction as the ownership change
+	transfer, err := s.store.AcceptTransfer(r.Context(), shortCode, user.ID)
+	if err != nil {
+		s.renderTransferError(w, err, "Failed to accept transfer")
+		return
+	}
+	s.cache.Invalidate(shortCode)
+	log.Printf("User %s accepted link %s from user %s", user.ID, shortCode, transfer.FromUserID)
+
+	// Success - redirect back to dashboard (root)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// HandleTransferDecline refuses a link offered to the user
+func (s *Server) handleTransferDecline(w http.ResponseWriter, r *http.Request, user *User, shortCode string) {
+	transfer, err := s.store.DeclineTransfer(r.Context(), shortCode, user.ID)
+	if err != nil {
+		s.renderTransferError(w, err, "Failed to decline transfer")
+		return
+	}
+
+	event := newAuditEvent(user.ID, AuditTransferDecline, shortCode)
+	event.SubjectID = &transfer.FromUserID
+	s.audit(r.Context(), event)
+
+	// Success - redirect back to dashboard (root)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// renderTransferError displays the error page matching a store error for a transfer offer
+func (s *Server) renderTransferError(w http.ResponseWriter, err error, failure string) {
+	if errors.Is(err, ErrNotFound) {
+		s.renderError(w, 404, "Transfer Not Found",
+			"There is no pending transfer for that link.",
+			fmt.Sprintf("It may have expired after %d days, been withdrawn, or the link may have changed hands.",
+				int(transferLifetime.Hours()/24)))
+		return
+	}
+	s.renderError(w, 500, "Database Error", failure, err.Error())
+}
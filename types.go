@@ -1,8 +1,8 @@
 package main
 
 import (
-	"database/sql"
 	"html/template"
+	"time"
 
 	disgoauth "github.com/realTristan/disgoauth"
 )
@@ -18,23 +18,188 @@ type Config struct {
 		Port         int64  `toml:"port"`
 		DatabasePath string `toml:"database_path"`
 		RedirectURI  string `toml:"redirect_uri"`
+		TrustProxy   bool   `toml:"trust_proxy"`
+		// TrustedProxyHops is how many reverse proxies in front of the server append to
+		// X-Forwarded-For when TrustProxy is set; defaults to 1
+		TrustedProxyHops int `toml:"trusted_proxy_hops"`
+		// ManualMigrations stops the server applying migrations at startup; run "drop-reg migrate" instead
+		ManualMigrations bool `toml:"manual_migrations"`
+		// Storage selects the backend: "sqlite" (default), "postgres", or "memory" for throwaway instances
+		Storage string `toml:"storage"`
+		// DatabaseURL is the connection URL used by the postgres backend
+		DatabaseURL string `toml:"database_url"`
+		// RedirectMode selects how short links are addressed: "subdomain" (default) for
+		// <code>.domain, "path" for domain/<code>, or "both"
+		RedirectMode string `toml:"redirect_mode"`
 	} `toml:"server"`
+	Jobs struct {
+		SessionCleanupInterval time.Duration `toml:"session_cleanup_interval"`
+		LinkCleanupInterval    time.Duration `toml:"link_cleanup_interval"`
+		ExpiredLinkGracePeriod time.Duration `toml:"expired_link_grace_period"`
+		// InviteCheckInterval is how often the invite health checker runs
+		InviteCheckInterval time.Duration `toml:"invite_check_interval"`
+		// InviteRecheckAge is how long a link's health result is trusted before it is checked again
+		InviteRecheckAge time.Duration `toml:"invite_recheck_age"`
+	} `toml:"jobs"`
+	Sessions struct {
+		// Lifetime is how long a login lasts however active it is
+		Lifetime time.Duration `toml:"lifetime"`
+		// IdleTimeout ends a session that hasn't made a request for this long
+		IdleTimeout time.Duration `toml:"idle_timeout"`
+	} `toml:"sessions"`
+	ShortCodes struct {
+		MinLength    int  `toml:"min_length"`
+		MaxLength    int  `toml:"max_length"`
+		AllowHyphens bool `toml:"allow_hyphens"`
+		// Reserved codes are refused in addition to the built-in infrastructure names
+		Reserved []string `toml:"reserved"`
+		// BannedWordsFile lists words, one per line, that may not appear anywhere in a code
+		BannedWordsFile string `toml:"banned_words_file"`
+	} `toml:"short_codes"`
+	Admin struct {
+		// UserIDs are the Discord user IDs allowed into the /admin area
+		UserIDs []string `toml:"user_ids"`
+		// ModeratorIDs may work the abuse report queue; admins always can
+		ModeratorIDs []string `toml:"moderator_ids"`
+	} `toml:"admin"`
+	Discord struct {
+		// APIBaseURL is the Discord REST API used to resolve invites; point it at a fake server in tests
+		APIBaseURL string `toml:"api_base_url"`
+		// VerifyGuilds requests the guilds scope at login and marks links whose registrant has
+		// Manage Server in the invite's guild as verified
+		VerifyGuilds bool `toml:"verify_guilds"`
+		// RequireVerified refuses links the registrant can't verify; it implies VerifyGuilds
+		RequireVerified bool `toml:"require_verified"`
+	} `toml:"discord"`
+	Cache struct {
+		// Size is the maximum number of cached short codes; negative disables the cache
+		Size        int           `toml:"size"`
+		TTL         time.Duration `toml:"ttl"`
+		NegativeTTL time.Duration `toml:"negative_ttl"`
+	} `toml:"cache"`
+	RateLimits struct {
+		Disabled bool          `toml:"disabled"`
+		Register RateLimitRule `toml:"register"`
+		Login    RateLimitRule `toml:"login"`
+		Redirect RateLimitRule `toml:"redirect"`
+		API      RateLimitRule `toml:"api"`
+		NotFound RateLimitRule `toml:"not_found"`
+		Report   RateLimitRule `toml:"report"`
+	} `toml:"rate_limits"`
+}
+
+// RateLimitRule configures a token-bucket rate limit
+type RateLimitRule struct {
+	PerMinute float64 `toml:"per_minute"`
+	Burst     int     `toml:"burst"`
 }
 
 // User represents a Discord user
 type User struct {
-	ID            string
-	Username      string
-	Avatar        string
+	ID string
+	// Username is the user's unique Discord handle
+	Username string
+	// GlobalName is the display name the user chose, if any
+	GlobalName string
+	// Avatar is the hash of the user's avatar image, empty for the default avatar
+	Avatar string
+	// Discriminator is "0" for accounts that have moved to unique usernames
 	Discriminator string
 	CreatedAt     string
+	// DisabledAt is set when an admin has disabled the account
+	DisabledAt *string
+}
+
+// UserSummary is a user as listed in the admin panel
+type UserSummary struct {
+	User
+	LinkCount int
+}
+
+// AdminLink is a link as listed in the admin panel
+type AdminLink struct {
+	URLMapping
+	OwnerUsername *string
+}
+
+// AdminQuery filters and orders the admin panel listings
+type AdminQuery struct {
+	// Search matches codes, URLs, guild names, usernames and user IDs, case-insensitively
+	Search string
+	// Sort is one of the listing's sort keys; unknown keys fall back to newest first
+	Sort  string
+	Desc  bool
+	Limit int
+}
+
+// LinkTransfer is a pending offer to hand a link to another Discord user
+type LinkTransfer struct {
+	ShortCode    string
+	DiscordURL   string
+	FromUserID   string
+	FromUsername string
+	ToUserID     string
+	CreatedAt    string
+	ExpiresAt    string
+}
+
+// LinkMember is a user who co-manages a link alongside its owner
+type LinkMember struct {
+	ShortCode  string
+	DiscordURL string
+	UserID     string
+	// Username is empty until the member has logged in
+	Username  string
+	Role      string
+	InvitedBy string
+	CreatedAt string
+	// AcceptedAt is nil while the invitation is pending
+	AcceptedAt *string
+}
+
+// AuditEvent is an append-only record of a link or account mutation
+type AuditEvent struct {
+	ID int
+	// ActorID is the user who made the change
+	ActorID *string
+	Action  string
+	// ShortCode is the link affected, if any
+	ShortCode *string
+	// SubjectID is the user affected when it isn't the actor, such as the owner of a link an
+	// admin deleted or the recipient of a reassigned link
+	SubjectID *string
+	OldURL    *string
+	NewURL    *string
+	Details   string
+	CreatedAt string
+}
+
+// AuditQuery filters the audit log
+type AuditQuery struct {
+	// UserID matches events where the user is either the actor or the subject
+	UserID    string
+	Action    string
+	ShortCode string
+	// Since and Until bound created_at; zero values are unbounded
+	Since time.Time
+	Until time.Time
+	Limit int
 }
 
 // Session represents a user session
 type Session struct {
-	ID        string
-	UserID    string
-	ExpiresAt string
+	// ID is the SHA-256 digest of the session token; the token itself is only ever in the cookie
+	ID         string
+	UserID     string
+	CreatedAt  string
+	LastSeenAt string
+	ExpiresAt  string
+	UserAgent  string
+	IPAddress  string
+	// Ref identifies the session on the sessions page without revealing its cookie value
+	Ref string
+	// Current marks the session the sessions page was requested with
+	Current bool
 }
 
 // URLMapping represents a database record
@@ -45,12 +210,68 @@ type URLMapping struct {
 	CreatedAt  string
 	ExpiresAt  *string
 	OwnerID    *string
+	UpdatedAt  *string
+	ExpiryDays *int
+
+	// Discord server the invite resolved to when it was registered or last edited
+	GuildID         *string
+	GuildName       *string
+	InviteExpiresAt *string
+
+	// DisabledAt is set when a moderator has disabled the link
+	DisabledAt *string
+
+	// VerifiedAt is set when the destination was last set by someone who manages its guild
+	VerifiedAt *string
+
+	// Result of the last invite health check, nil until the link has been checked
+	Health          *string
+	HealthCheckedAt *string
+
+	// Role is the listing user's role on the link, filled in by ListUserLinks
+	Role string
+
+	// Display fields filled in by the dashboard
+	Expired   bool
+	ExpiresIn string
+	Dead      bool
+}
+
+// Report is an abuse report filed against a short code
+type Report struct {
+	ID         int
+	ShortCode  string
+	DiscordURL string
+	OwnerID    *string
+	Reason     string
+	Details    string
+	ReporterID *string
+	Status     string
+	CreatedAt  string
+	ResolvedAt *string
+	ResolvedBy *string
+}
+
+// APIToken represents a personal access token for the JSON API
+type APIToken struct {
+	ID         int
+	Name       string
+	CreatedAt  string
+	LastUsedAt *string
 }
 
 // Server holds the application state
 type Server struct {
-	db          *sql.DB
+	store       Store
 	templates   *template.Template
 	discordAuth *disgoauth.Client
 	config      *Config
+	scheduler   *Scheduler
+	limiters    map[string]*RateLimiter
+	cache       *RedirectCache
+	discord     *DiscordClient
+	codePolicy  *ShortCodePolicy
+	stateKey    []byte
+	csrfKey     []byte
+	oauthStates *oauthStateLedger
 }
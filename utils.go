@@ -1,6 +1,125 @@
 package main
 
-import "regexp"
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
 
-// Discord URL validation regex
-var DiscordURLRegex = regexp.MustCompile(`^https://discord\.gg/[a-zA-Z0-9]+$`)
+// Hosts Discord hands out invites on, mapped to the path prefix the invite code follows
+var discordInviteHosts = map[string]string{
+	"discord.gg":         "/",
+	"www.discord.gg":     "/",
+	"discord.com":        "/invite/",
+	"www.discord.com":    "/invite/",
+	"ptb.discord.com":    "/invite/",
+	"canary.discord.com": "/invite/",
+	"discordapp.com":     "/invite/",
+	"www.discordapp.com": "/invite/",
+}
+
+// Invite code validation regex; vanity codes may contain hyphens
+var InviteCodeRegex = regexp.MustCompile(`^[a-zA-Z0-9-]{2,32}$`)
+
+// Scheduled event IDs are Discord snowflakes
+var eventIDRegex = regexp.MustCompile(`^[0-9]{1,20}$`)
+
+// errInvalidDiscordURL is returned for anything that isn't an official Discord invite URL
+var errInvalidDiscordURL = errors.New("Invalid Discord URL. Must be a Discord invite such as https://discord.gg/...")
+
+// DiscordInviteURL is a parsed Discord invite link
+type DiscordInviteURL struct {
+	Code string
+	// EventID is set for invites that open a scheduled event
+	EventID string
+}
+
+// String returns the canonical form stored for an invite
+func (u DiscordInviteURL) String() string {
+	canonical := "https://discord.gg/" + u.Code
+	if u.EventID != "" {
+		canonical += "?event=" + u.EventID
+	}
+	return canonical
+}
+
+// parseDiscordInviteURL parses any of the invite URL forms Discord hands out: discord.gg/<code>,
+// discord.com/invite/<code> and discordapp.com/invite/<code>, with or without https://,
+// trailing slash or ?event= parameter. Hosts are matched exactly so look-alikes are rejected.
+func parseDiscordInviteURL(raw string) (DiscordInviteURL, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.User != nil || u.Port() != "" {
+		return DiscordInviteURL{}, errInvalidDiscordURL
+	}
+
+	prefix, ok := discordInviteHosts[strings.ToLower(u.Hostname())]
+	if !ok {
+		return DiscordInviteURL{}, errInvalidDiscordURL
+	}
+
+	path := strings.TrimSuffix(u.Path, "/")
+	if !strings.HasPrefix(path, prefix) {
+		return DiscordInviteURL{}, errInvalidDiscordURL
+	}
+
+	invite := DiscordInviteURL{
+		Code:    strings.TrimPrefix(path, prefix),
+		EventID: u.Query().Get("event"),
+	}
+	if !InviteCodeRegex.MatchString(invite.Code) {
+		return DiscordInviteURL{}, errInvalidDiscordURL
+	}
+	if invite.EventID != "" && !eventIDRegex.MatchString(invite.EventID) {
+		return DiscordInviteURL{}, errInvalidDiscordURL
+	}
+	return invite, nil
+}
+
+// canonicalDiscordURL validates a Discord invite URL in any accepted form and returns
+// the canonical URL to store
+func canonicalDiscordURL(raw string) (string, error) {
+	invite, err := parseDiscordInviteURL(raw)
+	if err != nil {
+		return "", err
+	}
+	return invite.String(), nil
+}
+
+// Layout used when writing timestamps to the database, always in UTC
+const dbTimeLayout = "2006-01-02 15:04:05"
+
+// Layouts the database drivers may use when returning timestamp columns
+var timestampLayouts = []string{dbTimeLayout, time.RFC3339}
+
+// dbTime formats a time for the database. Timestamps are always passed in from Go rather than
+// taken from the database clock, so every backend stores and compares them the same way.
+func dbTime(t time.Time) string {
+	return t.UTC().Format(dbTimeLayout)
+}
+
+// parseTimestamp parses a database timestamp
+func parseTimestamp(value string) (time.Time, error) {
+	var err error
+	for _, layout := range timestampLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// formatTimestamp converts a database timestamp to a human readable form
+func formatTimestamp(value string) string {
+	if t, err := parseTimestamp(value); err == nil {
+		return t.Format("Jan 2, 2006 15:04")
+	}
+	return value
+}